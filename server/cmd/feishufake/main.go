@@ -0,0 +1,49 @@
+package main
+
+import (
+	"feishu2md/server/internal/feishu/feishutest"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+)
+
+// 本地飞书开放平台模拟服务，将配置中的 feishu.open_base_url 指向该服务即可离线运行转换流程
+//
+//	go run ./cmd/feishufake -addr :9090                          # 使用内置示例 fixture
+//	go run ./cmd/feishufake -fixtures ./fixtures                 # 使用目录中的 fixture
+//	go run ./cmd/feishufake -fixtures ./fixtures -record https://open.feishu.cn  # 转发并录制
+func main() {
+	addr := flag.String("addr", ":9090", "监听地址")
+	dir := flag.String("fixtures", "", "fixture 目录，为空时使用内置示例")
+	target := flag.String("record", "", "录制模式：转发到该开放平台地址并把响应写入 -fixtures 目录")
+	flag.Parse()
+
+	var handler http.Handler
+	if *target != "" {
+		if *dir == "" {
+			log.Fatal("录制模式需要指定 -fixtures 目录")
+		}
+		rec, err := feishutest.NewRecorder(*target, *dir)
+		if err != nil {
+			log.Fatalf("创建录制器失败: %v", err)
+		}
+		handler = rec
+		fmt.Printf("Recording %s into %s\n", *target, *dir)
+	} else {
+		var fixtures fs.FS = feishutest.SampleFixtures()
+		if *dir != "" {
+			fixtures = os.DirFS(*dir)
+		}
+		h, err := feishutest.NewHandler(fixtures)
+		if err != nil {
+			log.Fatalf("加载 fixture 失败: %v", err)
+		}
+		handler = h
+	}
+
+	fmt.Printf("Fake Feishu Open API listening on %s\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, handler))
+}
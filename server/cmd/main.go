@@ -3,26 +3,54 @@ package main
 import (
 	"context"
 	"feishu2md/server/internal/config"
+	"feishu2md/server/internal/container"
 	"feishu2md/server/internal/logger"
 	"feishu2md/server/internal/server"
 	"fmt"
+	"go.uber.org/zap"
+	"os"
 )
 
 func main() {
 	// 加载配置
-	cfg := config.LoadConfig()
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
 	// 打印配置验证
+	fmt.Printf("Profile: %s\n", cfg.Env)
 	fmt.Printf("Server Port: %s\n", cfg.Port)
 	fmt.Printf("Feishu AppID: %s\n", cfg.Feishu.AppID)
 
 	// 初始化日志
 	ctx := context.Background()
-	err := logger.Init(ctx, cfg.LogConfig) // 传递指针
+	err = logger.Init(ctx, cfg.LogConfig) // 传递指针
 	if err != nil {
 		panic("Failed to initialize logger: " + err.Error())
 	}
 
-	// 启动服务器
-	server.StartServer(cfg)
+	// 创建应用容器，数据库、Redis 等连接在整个进程内复用
+	app, err := container.New(cfg)
+	if err != nil {
+		logger.L.Fatal("Failed to initialize application", zap.Error(err))
+	}
+	defer app.Close()
+	// 监听配置文件变化
+	if err := app.Config.Watch(ctx); err != nil {
+		logger.L.Warn("Failed to watch config files, use POST /admin/config/reload instead", zap.Error(err))
+	}
+
+	// 后台刷新即将过期的飞书用户令牌
+	app.Accounts.Start(ctx)
+	// Redis 不可用期间定期检查其是否恢复
+	app.Cache.Start(ctx)
+
+	// 启动服务器，os.Exit 不执行 defer，退出前先关闭容器
+	if err := server.StartServer(app); err != nil {
+		logger.L.Error("Failed to start server", zap.Error(err))
+		app.Close()
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"feishu2md/server/internal/config"
+	"feishu2md/server/internal/repository/database"
+	"feishu2md/server/internal/search"
+	"fmt"
+	"os"
+	"time"
+)
+
+// 重建历史记录全文索引：清空索引后从数据库重新写入全部历史记录，配置与服务相同（APP_ENV 等）
+//
+//	go run ./cmd/searchindex
+func main() {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	db, err := database.Open(*cfg.Database)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "连接数据库失败: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	index, err := search.Open(cfg.Search.Path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "打开索引失败: %v\n", err)
+		os.Exit(1)
+	}
+	defer index.Close()
+
+	start := time.Now()
+	n, err := index.Rebuild(context.Background(), database.NewTransformRepository(db).ListAfter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "重建索引失败（已写入 %d 条）: %v\n", n, err)
+		os.Exit(1)
+	}
+	fmt.Printf("已索引 %d 条历史记录，用时 %s\n", n, time.Since(start).Round(time.Millisecond))
+}
@@ -1,65 +1,371 @@
 package config
 
 import (
+	"errors"
 	"feishu2md/server/pkg/conf"
 	"fmt"
 	"github.com/go-viper/mapstructure/v2"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
 )
 import "github.com/spf13/viper"
 
+const (
+	// EnvPrefix 覆盖配置项的环境变量前缀，如 FEISHU2MD_FEISHU_APP_SECRET 覆盖 feishu.app_secret
+	EnvPrefix = "FEISHU2MD"
+	// FileSuffix 以该后缀结尾的环境变量表示从文件读取对应配置项，如 FEISHU2MD_FEISHU_APP_SECRET_FILE
+	FileSuffix = "_FILE"
+	// DefaultEnv 未设置 APP_ENV 时使用的 profile
+	DefaultEnv = "development"
+)
+
+// minSecretLen HMAC 签名密钥的最小长度
+const minSecretLen = 32
+
+// rateFamilies 可配置限流额度的开放平台 API 类别
+var rateFamilies = map[string]bool{"blocks": true, "media": true, "sheets": true, "bitable": true}
+
+// searchPaths 查找配置目录的路径，可通过 FEISHU2MD_CONFIG_DIR 指定
+var searchPaths = []string{"./internal/config", "./server/internal/config", "/etc/feishu2md"}
+
 type Config struct {
-	Port      string              `yaml:"port"`
-	LogConfig *conf.LogConfig     `yaml:"log"`    // 改为指针类型
-	Feishu    *conf.FeishuConfig  `yaml:"feishu"` // 改为指针类型
-	Storage   *conf.StorageConfig `yaml:"storage"`
-	ImgConfig *conf.ImgConfig     `yaml:"image"`
-	CptConfig *conf.CaptchaConfig `yaml:"captcha"`
+	// Env 当前 profile，来自 APP_ENV
+	Env string `yaml:"-"`
+	// Dir 配置文件所在目录
+	Dir       string               `yaml:"-"`
+	Port      string               `yaml:"port"`
+	LogConfig *conf.LogConfig      `yaml:"log"`    // 改为指针类型
+	Feishu    *conf.FeishuConfig   `yaml:"feishu"` // 改为指针类型
+	Storage   *conf.StorageConfig  `yaml:"storage"`
+	ImgConfig *conf.ImgConfig      `yaml:"image"`
+	CptConfig *conf.CaptchaConfig  `yaml:"captcha"`
+	Database  *conf.DatabaseConfig `yaml:"database"`
+	Redis     *conf.RedisConfig    `yaml:"redis"`
+	CORS      *conf.CORSConfig     `yaml:"cors"`
+	Admin     *conf.AdminConfig    `yaml:"admin"`
+	Search    *conf.SearchConfig   `yaml:"search"`
+	APIKey    *conf.APIKeyConfig   `yaml:"api_key"`
+	JWT       *conf.JWTConfig      `yaml:"jwt"`
+	// TokenVault 飞书用户令牌的加密与刷新
+	TokenVault *conf.TokenVaultConfig `yaml:"token_vault"`
+
+	// developmentSecrets development profile 中提交的公开密钥，未显式设置 APP_ENV=development 时不允许使用
+	developmentSecrets map[string]string
 }
 
-func LoadConfig() *Config {
-	v := viper.New()
+// secretKeys 不允许沿用 development profile 取值的密钥配置项
+var secretKeys = []string{"jwt.secret", "token_vault.secret", "storage.url_secret"}
+
+// LoadConfig 依次读取 base.yaml、<APP_ENV>.yaml，再用环境变量与 *_FILE 指向的文件覆盖，最后校验全部配置项
+func LoadConfig() (*Config, error) {
+	env := os.Getenv("APP_ENV")
+	explicit := env != ""
+	if !explicit {
+		env = DefaultEnv
+	}
 
-	// 配置文件设置
-	v.SetConfigName("base")              // 配置文件名称（不带扩展名）
-	v.SetConfigType("yaml")              // 配置文件格式
-	v.AddConfigPath("./internal/config") // 查找当前目录
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
 
-	// 读取配置
+	v := viper.New()
+	v.SetConfigType("yaml")
+	v.SetConfigFile(filepath.Join(dir, "base.yaml"))
 	if err := v.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			fmt.Println("❌ Config file not found in paths:")
-		} else {
-			fmt.Println("❌ Config file error:", err)
+		return nil, fmt.Errorf("failed to read config %s: %w", v.ConfigFileUsed(), err)
+	}
+	// profile 文件只覆盖其中出现的配置项；显式指定的 profile 必须存在
+	profile := filepath.Join(dir, env+".yaml")
+	if _, err := os.Stat(profile); err == nil {
+		v.SetConfigFile(profile)
+		if err := v.MergeInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to read config %s: %w", profile, err)
 		}
-		panic(fmt.Errorf("failed to read config: %w", err))
-	} else {
-		fmt.Println("✅ Using config file:", v.ConfigFileUsed())
+	} else if explicit {
+		return nil, fmt.Errorf("config profile %q not found: %w", env, err)
 	}
 
-	// 调试输出
-	fmt.Println("All Settings:", v.AllSettings())
-
-	// 初始化嵌套字段
-	cfg := &Config{
-		LogConfig: &conf.LogConfig{},    // 初始化 LogConfig
-		Feishu:    &conf.FeishuConfig{}, // 初始化 Feishu
+	var errs []error
+	for _, key := range configKeys(reflect.TypeOf(Config{}), "") {
+		name := envName(key)
+		if err := v.BindEnv(key, name); err != nil {
+			errs = append(errs, err)
+		}
+		if file := os.Getenv(name + FileSuffix); file != "" {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: %w", name, FileSuffix, err))
+				continue
+			}
+			v.Set(key, strings.TrimSpace(string(data)))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
 	}
 
+	cfg := &Config{Env: env, Dir: dir}
+	if !explicit || env != DefaultEnv {
+		if cfg.developmentSecrets, err = loadDevelopmentSecrets(dir); err != nil {
+			return nil, err
+		}
+	}
 	// 解析配置时添加解码器选项
 	if err := v.Unmarshal(cfg, func(decoderConfig *mapstructure.DecoderConfig) {
 		decoderConfig.TagName = "yaml"        // 强制使用 yaml 标签
 		decoderConfig.WeaklyTypedInput = true // 允许弱类型转换
 	}); err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to decode config: %w", err)
 	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadDevelopmentSecrets 读取 development profile 中的密钥，文件不存在时返回空
+func loadDevelopmentSecrets(dir string) (map[string]string, error) {
+	profile := filepath.Join(dir, DefaultEnv+".yaml")
+	if _, err := os.Stat(profile); err != nil {
+		return nil, nil
+	}
+	v := viper.New()
+	v.SetConfigFile(profile)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", profile, err)
+	}
+	secrets := make(map[string]string)
+	for _, key := range secretKeys {
+		if secret := v.GetString(key); secret != "" {
+			secrets[key] = secret
+		}
+	}
+	return secrets, nil
+}
 
-	// 验证必要字段
-	if cfg.Feishu.AppID == "" {
-		panic("Missing feishu.app_id in config")
+// configDir 返回配置目录
+func configDir() (string, error) {
+	if dir := os.Getenv(EnvPrefix + "_CONFIG_DIR"); dir != "" {
+		return dir, nil
 	}
-	if cfg.Feishu.AppSecret == "" {
-		panic("Missing feishu.app_secret in config")
+	for _, dir := range searchPaths {
+		if _, err := os.Stat(filepath.Join(dir, "base.yaml")); err == nil {
+			return dir, nil
+		}
 	}
+	return "", fmt.Errorf("base.yaml not found in %s, set %s_CONFIG_DIR", strings.Join(searchPaths, ", "), EnvPrefix)
+}
+
+// configKeys 按 yaml 标签列出 Config 中的全部配置项，如 feishu.app_id
+func configKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct {
+			keys = append(keys, configKeys(fieldType, prefix+tag+".")...)
+			continue
+		}
+		keys = append(keys, prefix+tag)
+	}
+	return keys
+}
 
-	return cfg
+// envName 配置项对应的环境变量名
+func envName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Validate 检查配置，一次返回所有问题
+func (c *Config) Validate() error {
+	var errs []error
+	require := func(ok bool, key, msg string) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s (%s)", key, msg, envName(key)))
+		}
+	}
+
+	require(c.Port != "", "port", "is required")
+	require(c.LogConfig != nil, "log", "section is required")
+	require(c.Feishu != nil, "feishu", "section is required")
+	if c.Feishu != nil {
+		require(c.Feishu.AppID != "", "feishu.app_id", "is required")
+		require(c.Feishu.AppSecret != "", "feishu.app_secret", "is required")
+		domains := map[string]bool{"feishu.cn": true}
+		for i, t := range c.Feishu.Tenants {
+			name := fmt.Sprintf("feishu.tenants[%d]", i)
+			require(t.Domain != "" && !domains[t.Domain], name+".domain", "must be unique and not feishu.cn, which uses feishu.app_id")
+			require(t.AppID != "", name+".app_id", "is required")
+			require(t.AppSecret != "", name+".app_secret", "is required")
+			domains[t.Domain] = true
+		}
+		require(c.Feishu.TokenRefreshBefore >= 0 && c.Feishu.TokenRefreshBefore < time.Hour, "feishu.token_refresh_before", "must be between 0 and 1h")
+		require(c.Feishu.HTTP.Timeout > 0, "feishu.http.timeout", "must be positive")
+		require(c.Feishu.HTTP.DialTimeout >= 0, "feishu.http.dial_timeout", "must not be negative")
+		require(c.Feishu.HTTP.IdleConnTimeout >= 0, "feishu.http.idle_conn_timeout", "must not be negative")
+		require(c.Feishu.HTTP.MaxIdleConnsPerHost >= 0, "feishu.http.max_idle_conns_per_host", "must not be negative")
+		require(c.Feishu.Retry.MaxAttempts >= 1, "feishu.retry.max_attempts", "must be at least 1")
+		require(c.Feishu.Retry.InitialInterval > 0, "feishu.retry.initial_interval", "must be positive")
+		require(c.Feishu.Retry.MaxInterval >= c.Feishu.Retry.InitialInterval, "feishu.retry.max_interval", "must not be shorter than feishu.retry.initial_interval")
+		require(c.Feishu.Retry.MaxElapsedTime >= 0, "feishu.retry.max_elapsed_time", "must not be negative")
+		rl := c.Feishu.RateLimit
+		require(rl.Backend == "redis" || rl.Backend == "memory", "feishu.rate_limit.backend", fmt.Sprintf("unsupported backend %q, want redis or memory", rl.Backend))
+		budgets := map[string]map[string]conf.RateBudget{"feishu.rate_limit.budgets": rl.Budgets}
+		for name, tenantBudgets := range rl.Tenants {
+			budgets["feishu.rate_limit.tenants."+name] = tenantBudgets
+		}
+		for prefix, families := range budgets {
+			for family, budget := range families {
+				key := prefix + "." + family
+				require(rateFamilies[family], key, "unknown API family, want blocks, media, sheets or bitable")
+				require(budget.Rate >= 0, key+".rate", "must not be negative")
+				require(budget.Burst >= 0, key+".burst", "must not be negative")
+			}
+		}
+	}
+	require(c.Storage != nil, "storage", "section is required")
+	if c.Storage != nil {
+		require(c.Storage.Type == "local" || c.Storage.Type == "fds", "storage.type", fmt.Sprintf("unsupported type %q", c.Storage.Type))
+		if c.Storage.Type == "local" {
+			require(c.Storage.LocalDir != "", "storage.local_dir", "is required for local storage")
+			require(len(c.Storage.URLSecret) >= minSecretLen, "storage.url_secret", fmt.Sprintf("must be at least %d bytes for local storage", minSecretLen))
+		}
+		if c.Storage.Type == "fds" {
+			require(c.Storage.Endpoint != "", "storage.endpoint", "is required for fds storage")
+			require(c.Storage.Bucket != "", "storage.bucket", "is required for fds storage")
+		}
+	}
+	require(c.ImgConfig != nil, "image", "section is required")
+	if c.ImgConfig != nil {
+		require(c.ImgConfig.DownloadRate > 0, "image.download_rate", "must be positive")
+		require(c.ImgConfig.MaxWaitTime >= 0, "image.max_wait_time", "must not be negative")
+		require(c.ImgConfig.MaxPixels >= 0, "image.max_pixels", "must not be negative")
+		process := c.ImgConfig.Process
+		require(process.MaxWidth >= 0, "image.process.max_width", "must not be negative")
+		require(process.ThumbnailWidth >= 0, "image.process.thumbnail_width", "must not be negative")
+		require(process.Quality >= 0 && process.Quality <= 100, "image.process.quality", "must be between 0 and 100")
+		switch process.Format {
+		case "", "jpeg", "png":
+		case "webp":
+			require(c.ImgConfig.CWebPPath != "", "image.cwebp_path", "is required for webp")
+		default:
+			require(false, "image.process.format", fmt.Sprintf("unsupported format %q, want jpeg, png or webp", process.Format))
+		}
+	}
+	require(c.CptConfig != nil, "captcha", "section is required")
+	if c.CptConfig != nil && !c.CptConfig.RandomCaptcha {
+		switch c.CptConfig.CaptchaType {
+		case "string", "math", "digit":
+		default:
+			require(false, "captcha.captcha_type", fmt.Sprintf("unsupported type %q", c.CptConfig.CaptchaType))
+		}
+	}
+	require(c.Database != nil, "database", "section is required")
+	if c.Database != nil {
+		switch c.Database.Type {
+		case "sqlite":
+			require(c.Database.Path != "", "database.path", "is required for sqlite")
+		case "mysql", "postgres":
+			require(c.Database.Host != "", "database.host", "is required")
+			require(c.Database.Port > 0, "database.port", "must be positive")
+			require(c.Database.Username != "", "database.username", "is required")
+			require(c.Database.DBName != "", "database.dbname", "is required")
+		default:
+			require(false, "database.type", fmt.Sprintf("unsupported type %q, want mysql, postgres or sqlite", c.Database.Type))
+		}
+	}
+	require(c.Search != nil, "search", "section is required")
+	if c.Search != nil {
+		require(c.Search.Path != "", "search.path", "is required")
+	}
+	require(c.JWT != nil, "jwt", "section is required")
+	if c.JWT != nil {
+		require(c.JWT.KeyID != "", "jwt.key_id", "is required")
+		require(len(c.JWT.Secret) >= minSecretLen, "jwt.secret", fmt.Sprintf("must be at least %d bytes", minSecretLen))
+		require(c.JWT.AccessTTL > 0, "jwt.access_ttl", "must be positive")
+		require(c.JWT.RefreshTTL > c.JWT.AccessTTL, "jwt.refresh_ttl", "must be longer than jwt.access_ttl")
+		ids := map[string]bool{c.JWT.KeyID: true}
+		for i, key := range c.JWT.PreviousKeys {
+			name := fmt.Sprintf("jwt.previous_keys[%d]", i)
+			require(key.ID != "" && !ids[key.ID], name+".id", "must be unique and not empty")
+			require(len(key.Secret) >= minSecretLen, name+".secret", fmt.Sprintf("must be at least %d bytes", minSecretLen))
+			ids[key.ID] = true
+		}
+	}
+	require(c.TokenVault != nil, "token_vault", "section is required")
+	if c.TokenVault != nil {
+		require(len(c.TokenVault.Secret) >= minSecretLen, "token_vault.secret", fmt.Sprintf("must be at least %d bytes", minSecretLen))
+		for i, secret := range c.TokenVault.PreviousSecrets {
+			require(len(secret) >= minSecretLen, fmt.Sprintf("token_vault.previous_secrets[%d]", i), fmt.Sprintf("must be at least %d bytes", minSecretLen))
+		}
+		require(c.TokenVault.RefreshInterval >= 0, "token_vault.refresh_interval", "must not be negative")
+		require(c.TokenVault.RefreshBefore > c.TokenVault.RefreshInterval, "token_vault.refresh_before", "must be longer than token_vault.refresh_interval")
+	}
+	require(c.APIKey != nil, "api_key", "section is required")
+	if c.APIKey != nil {
+		require(c.APIKey.RateLimit >= 0, "api_key.rate_limit", "must not be negative")
+		require(c.APIKey.DailyQuota >= 0, "api_key.daily_quota", "must not be negative")
+		require(c.APIKey.MaxPerUser >= 0, "api_key.max_per_user", "must not be negative")
+	}
+	require(c.Redis != nil, "redis", "section is required")
+	if c.Redis != nil {
+		switch c.Redis.Mode {
+		case "", "standalone":
+			require(c.Redis.Addr != "", "redis.addr", "is required")
+		case "sentinel":
+			require(len(c.Redis.Addrs) > 0, "redis.addrs", "is required for sentinel")
+			require(c.Redis.MasterName != "", "redis.master_name", "is required for sentinel")
+		case "cluster":
+			require(len(c.Redis.Addrs) > 0, "redis.addrs", "is required for cluster")
+			require(c.Redis.DB == 0, "redis.db", "must be 0 for cluster")
+		default:
+			require(false, "redis.mode", fmt.Sprintf("unsupported mode %q, want standalone, sentinel or cluster", c.Redis.Mode))
+		}
+		require(c.Redis.DB >= 0, "redis.db", "must not be negative")
+		require(c.Redis.PoolSize >= 0, "redis.pool_size", "must not be negative")
+		require(c.Redis.MinIdleConns >= 0, "redis.min_idle_conns", "must not be negative")
+		require((c.Redis.TLS.CertFile == "") == (c.Redis.TLS.KeyFile == ""), "redis.tls.cert_file", "must be set together with redis.tls.key_file")
+		require(c.Redis.HealthInterval >= 0, "redis.health_interval", "must not be negative")
+	}
+	require(c.CORS != nil, "cors", "section is required")
+	if c.CORS != nil {
+		require(len(c.CORS.AllowOrigins) > 0, "cors.allow_origins", "is required")
+	}
+	if len(c.developmentSecrets) > 0 {
+		current := make(map[string]string)
+		if c.JWT != nil {
+			current["jwt.secret"] = c.JWT.Secret
+		}
+		if c.TokenVault != nil {
+			current["token_vault.secret"] = c.TokenVault.Secret
+		}
+		if c.Storage != nil {
+			current["storage.url_secret"] = c.Storage.URLSecret
+		}
+		for _, key := range secretKeys {
+			secret, ok := c.developmentSecrets[key]
+			require(!ok || current[key] != secret, key, "must not use the committed development value unless APP_ENV=development is set")
+		}
+	}
+	if c.LogConfig != nil {
+		_, err := zap.ParseAtomicLevel(c.LogConfig.Level)
+		require(err == nil, "log.level", fmt.Sprintf("unsupported level %q", c.LogConfig.Level))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config (APP_ENV=%s):\n%w", c.Env, errors.Join(errs...))
+	}
+	return nil
 }
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"context"
+	"feishu2md/server/internal/logger"
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// reloadDelay 配置文件变化后等待的时间，编辑器保存时往往触发多次写入
+const reloadDelay = 300 * time.Millisecond
+
+// Watcher 持有当前生效的配置，重新加载成功后原子替换并通知订阅者
+type Watcher struct {
+	current atomic.Pointer[Config]
+
+	mu          sync.Mutex // 串行化重新加载与订阅
+	subscribers []func(old, cfg *Config)
+}
+
+// NewWatcher 以 cfg 作为当前配置创建 Watcher
+func NewWatcher(cfg *Config) *Watcher {
+	w := &Watcher{}
+	w.current.Store(cfg)
+	return w
+}
+
+// Current 返回当前生效的配置，调用方不应修改返回值
+func (w *Watcher) Current() *Config {
+	return w.current.Load()
+}
+
+// Subscribe 注册配置变化的回调，回调在重新加载的 goroutine 中同步执行
+func (w *Watcher) Subscribe(fn func(old, cfg *Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// Reload 重新读取配置文件与环境变量，校验失败时保留原配置并返回错误
+func (w *Watcher) Reload() (*Config, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	old := w.current.Swap(cfg)
+	if keys := RestartRequired(old, cfg); len(keys) > 0 {
+		logger.L.Warn("Config changes take effect after restart", zap.Strings("keys", keys))
+	}
+	for _, fn := range w.subscribers {
+		fn(old, cfg)
+	}
+	return cfg, nil
+}
+
+// Watch 监听配置目录，base.yaml 或当前 profile 文件变化时自动重新加载，ctx 结束后停止
+func (w *Watcher) Watch(ctx context.Context) error {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// 监听目录而不是文件，编辑器通过重命名保存时文件本身会被替换
+	if err := fsWatcher.Add(w.Current().Dir); err != nil {
+		fsWatcher.Close()
+		return err
+	}
+
+	go func() {
+		defer fsWatcher.Close()
+		var timer *time.Timer
+		for {
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			case event, ok := <-fsWatcher.Events:
+				if !ok {
+					return
+				}
+				if !w.watched(event.Name) || !event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(reloadDelay, w.reloadFromWatch)
+			case err, ok := <-fsWatcher.Errors:
+				if !ok {
+					return
+				}
+				logger.L.Error("Config watcher error", zap.Error(err))
+			}
+		}
+	}()
+	return nil
+}
+
+func (w *Watcher) watched(name string) bool {
+	cfg := w.Current()
+	switch filepath.Base(name) {
+	case "base.yaml", cfg.Env + ".yaml":
+		return true
+	}
+	return false
+}
+
+func (w *Watcher) reloadFromWatch() {
+	if _, err := w.Reload(); err != nil {
+		logger.L.Error("Config reload rejected, keeping previous config", zap.Error(err))
+		return
+	}
+	logger.L.Info("Config reloaded")
+}
+
+// RestartRequired 返回发生变化但需要重启才能生效的配置段
+func RestartRequired(old, cfg *Config) []string {
+	var keys []string
+	if old.Port != cfg.Port {
+		keys = append(keys, "port")
+	}
+	for _, section := range []struct {
+		key      string
+		old, cfg interface{}
+	}{
+		{"feishu", old.Feishu, cfg.Feishu},
+		{"database", old.Database, cfg.Database},
+		{"redis", old.Redis, cfg.Redis},
+		{"storage", old.Storage, cfg.Storage},
+		{"search", old.Search, cfg.Search},
+		{"api_key", old.APIKey, cfg.APIKey},
+		{"token_vault", old.TokenVault, cfg.TokenVault},
+	} {
+		if !reflect.DeepEqual(section.old, section.cfg) {
+			keys = append(keys, section.key)
+		}
+	}
+	return keys
+}
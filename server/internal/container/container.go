@@ -0,0 +1,210 @@
+// Package container 在启动时一次性创建配置、数据库、Redis、存储、飞书客户端与各业务服务，
+// 由 handler 复用，避免每个请求重新读取配置和建立连接。
+package container
+
+import (
+	"context"
+	"errors"
+	"feishu2md/server/internal/config"
+	"feishu2md/server/internal/feishu"
+	"feishu2md/server/internal/logger"
+	"feishu2md/server/internal/ratelimit"
+	"feishu2md/server/internal/repository/cache"
+	"feishu2md/server/internal/repository/database"
+	"feishu2md/server/internal/repository/storage"
+	"feishu2md/server/internal/search"
+	"feishu2md/server/internal/service/account"
+	"feishu2md/server/internal/service/apikey"
+	"feishu2md/server/internal/service/auth"
+	"feishu2md/server/internal/service/captcha"
+	"feishu2md/server/internal/service/img"
+	"feishu2md/server/internal/service/revision"
+	"feishu2md/server/internal/service/token"
+	services "feishu2md/server/internal/service/transform"
+	user2 "feishu2md/server/internal/service/user"
+	"feishu2md/server/internal/tenant"
+	"feishu2md/server/internal/vault"
+	"feishu2md/server/pkg/conf"
+	"go.uber.org/zap"
+	"sync"
+)
+
+// Container 应用运行期间共享的依赖，字段均可直接赋值，便于在测试中替换为模拟实现
+type Container struct {
+	// Config 当前生效的配置，支持运行时重新加载
+	Config *config.Watcher
+	DB     *database.DB
+	// Cache 优先使用 Redis 的缓存，Redis 不可用时降级为进程内缓存
+	Cache *cache.Failover
+	// LocalStorage 提供 /storage 下的静态文件
+	LocalStorage *storage.LocalStorage
+	// Storage 图片上传使用的对象存储
+	Storage storage.ObjectStorage
+	// Search 历史记录全文索引
+	Search *search.Index
+	// Tenants 文档域名对应的飞书应用
+	Tenants *tenant.Registry
+	// Feishu 按域名与应用复用的飞书客户端
+	Feishu *feishu.Pool
+
+	Transforms *services.TransformService
+	Users      *user2.UserService
+	Auth       *auth.Service
+	Captcha    *captcha.CaptService
+	APIKeys    *apikey.Service
+	Tokens     *token.Service
+	Accounts   *account.Service
+
+	mu         sync.Mutex
+	processors map[string]*img.Processor
+}
+
+// New 按配置建立数据库、Redis 与存储连接并创建各服务，任一依赖失败时释放已建立的连接
+func New(cfg *config.Config) (*Container, error) {
+	c := &Container{Config: config.NewWatcher(cfg)}
+	if err := c.init(cfg); err != nil {
+		c.Close()
+		return nil, err
+	}
+	c.Config.Subscribe(c.onConfigChange)
+	return c, nil
+}
+
+func (c *Container) init(cfg *config.Config) (err error) {
+	if c.DB, err = database.Open(*cfg.Database); err != nil {
+		return err
+	}
+	// 启动时执行尚未执行的数据库迁移
+	applied, err := database.Migrate(context.Background(), c.DB)
+	if err != nil {
+		return err
+	}
+	for _, m := range applied {
+		logger.L.Info("Applied database migration", zap.Int("version", m.Version), zap.String("name", m.Name))
+	}
+	// Redis 不可用时不阻止启动，缓存与限流先使用进程内实现，恢复后自动切换
+	redis, err := cache.DialRedis(*cfg.Redis)
+	if err != nil {
+		return err
+	}
+	c.Cache = cache.NewFailover(context.Background(), redis, cache.NewMemory(), cfg.Redis.HealthInterval)
+	if err = c.initStorage(*cfg.Storage); err != nil {
+		return err
+	}
+
+	if c.Search, err = search.Open(cfg.Search.Path); err != nil {
+		return err
+	}
+
+	c.Transforms = services.NewTransformService(database.NewTransformRepository(c.DB))
+	c.Transforms.SetIndexer(c.Search)
+	users := database.NewUserRepository(c.DB)
+	c.Users = user2.NewUserService(users)
+	c.Tenants = tenant.NewRegistry(*cfg.Feishu)
+	c.Feishu = feishu.NewPool(*cfg.Feishu, c.Cache, c.rateLimiter(cfg.Feishu.RateLimit))
+	c.Auth = auth.NewAuthService(c.Tenants)
+	tokenVault, err := vault.New(cfg.TokenVault.Secret, cfg.TokenVault.PreviousSecrets...)
+	if err != nil {
+		return err
+	}
+	c.Accounts = account.NewAccountService(c.Auth, database.NewFeishuAccountRepository(c.DB, tokenVault), users, c.Cache, *cfg.TokenVault)
+	var captchaConfig conf.CaptchaConfig
+	if cfg.CptConfig != nil {
+		captchaConfig = *cfg.CptConfig
+	}
+	c.Captcha = captcha.NewCaptService(captchaConfig)
+	c.Tokens = token.NewTokenService(*cfg.JWT, c.Cache)
+	c.APIKeys = apikey.NewAPIKeyService(database.NewAPIKeyRepository(c.DB), c.Cache, *cfg.APIKey)
+	return nil
+}
+
+// rateLimiter 按配置选择限流的存储，memory 只在本实例内限流；redis 在 Redis 不可用期间同样改为本实例内限流
+func (c *Container) rateLimiter(cfg conf.RateLimitConfig) ratelimit.Limiter {
+	if cfg.Backend == "memory" {
+		return ratelimit.NewMemory()
+	}
+	return ratelimit.WithFallback(ratelimit.NewRedis(c.Cache.Redis().Client(), c.Cache.Redis().KeyPrefix()), ratelimit.NewMemory(), c.Cache)
+}
+
+// initStorage 创建本地存储与对象存储，存储类型为本地时二者为同一实例
+func (c *Container) initStorage(cfg conf.StorageConfig) error {
+	local, err := storage.NewLocalStorage(cfg.LocalDir, cfg.URLSecret)
+	if err != nil {
+		return err
+	}
+	c.LocalStorage = local
+	if cfg.Type == string(storage.StorageTypeLocal) {
+		c.Storage = local
+		return nil
+	}
+	c.Storage, err = storage.InitStorageClient(cfg)
+	return err
+}
+
+// FeishuClient 返回指定域名的飞书客户端，使用该域名租户的应用凭证，客户端与 tenant_access_token 由连接池复用；
+// 域名不属于任何租户时返回 tenant.ErrUnknownDomain
+func (c *Container) FeishuClient(domain string) (*feishu.Client, error) {
+	t, err := c.Tenants.Resolve(domain)
+	if err != nil {
+		return nil, err
+	}
+	return c.Feishu.Client(t.AppID, t.AppSecret, domain, feishu.WithOpenBaseURL(t.OpenBaseURL), feishu.WithTenant(t.Name)), nil
+}
+
+// ImageProcessor 返回指定域名的图片处理器，配置重新加载后处理器的下载参数随之更新
+func (c *Container) ImageProcessor(domain string) (*img.Processor, error) {
+	client, err := c.FeishuClient(domain)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if processor, ok := c.processors[domain]; ok {
+		return processor, nil
+	}
+	if c.processors == nil {
+		c.processors = make(map[string]*img.Processor)
+	}
+	processor := img.NewProcessor(c.Cache, c.Storage, *client, *c.Config.Current().ImgConfig)
+	c.processors[domain] = processor
+	return processor, nil
+}
+
+// onConfigChange 将重新加载的配置应用到已创建的依赖
+func (c *Container) onConfigChange(old, cfg *config.Config) {
+	if level, err := zap.ParseAtomicLevel(cfg.LogConfig.Level); err == nil {
+		logger.AtomicLevel.SetLevel(level.Level())
+	}
+	// 轮换后的签名密钥立即用于签发，旧密钥仍可验证
+	c.Tokens.SetConfig(*cfg.JWT)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, processor := range c.processors {
+		processor.SetConfig(*cfg.ImgConfig)
+	}
+}
+
+// Revisions 创建指定域名的文档版本服务
+func (c *Container) Revisions(domain string) (*revision.Service, error) {
+	client, err := c.FeishuClient(domain)
+	if err != nil {
+		return nil, err
+	}
+	return revision.NewRevisionService(client), nil
+}
+
+// Close 关闭数据库、Redis 连接与全文索引
+func (c *Container) Close() error {
+	var errs []error
+	if c.DB != nil {
+		errs = append(errs, c.DB.Close())
+	}
+	if c.Cache != nil {
+		errs = append(errs, c.Cache.Close())
+	}
+	if c.Search != nil {
+		errs = append(errs, c.Search.Close())
+	}
+	return errors.Join(errs...)
+}
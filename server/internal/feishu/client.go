@@ -4,13 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"feishu2md/server/internal/config"
 	"feishu2md/server/internal/model"
+	"feishu2md/server/internal/progress"
+	"feishu2md/server/internal/ratelimit"
+	"feishu2md/server/pkg/conf"
 	"fmt"
 	"github.com/Wsine/feishu2md/core"
 	"github.com/chyroc/lark"
 	"log"
 	"math/rand"
+	"net/http"
 	"net/url"
 	"path/filepath"
 	"sort"
@@ -24,16 +27,110 @@ type Client struct {
 	client *lark.Lark
 }
 
-func NewClient(appID, appSecret, domain string) *Client {
-	return &Client{
-		client: lark.New(
-			lark.WithAppCredential(appID, appSecret),
-			lark.WithOpenBaseURL("https://open."+domain),
-			lark.WithTimeout(60*time.Second),
-		),
+// ClientOption 创建 Client 时的可选配置
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	openBaseURL string
+	tenant      string
+	store       lark.Store
+	transport   http.RoundTripper
+	timeout     time.Duration
+	retry       conf.FeishuRetryConfig
+	limiter     ratelimit.Limiter
+	rateLimit   conf.RateLimitConfig
+}
+
+// WithOpenBaseURL 指定开放平台地址，默认为 https://open.<domain>，可指向本地的模拟服务
+func WithOpenBaseURL(baseURL string) ClientOption {
+	return func(o *clientOptions) {
+		if baseURL != "" {
+			o.openBaseURL = strings.TrimRight(baseURL, "/")
+		}
+	}
+}
+
+// WithTenant 指定租户名，开放平台请求的监控指标按租户区分，默认为文档域名
+func WithTenant(name string) ClientOption {
+	return func(o *clientOptions) {
+		if name != "" {
+			o.tenant = name
+		}
+	}
+}
+
+// WithStore 指定 tenant_access_token 的缓存，默认缓存在客户端自身
+func WithStore(store lark.Store) ClientOption {
+	return func(o *clientOptions) {
+		if store != nil {
+			o.store = store
+		}
+	}
+}
+
+// WithHTTP 指定共享的 HTTP 连接池与单个请求的超时，默认为 http.DefaultTransport 与 60s
+func WithHTTP(transport http.RoundTripper, timeout time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		if transport != nil {
+			o.transport = transport
+		}
+		if timeout > 0 {
+			o.timeout = timeout
+		}
+	}
+}
+
+// WithRetry 指定请求的重试策略，默认为 DefaultRetryPolicy
+func WithRetry(policy conf.FeishuRetryConfig) ClientOption {
+	return func(o *clientOptions) {
+		if policy.MaxAttempts > 0 {
+			o.retry = policy
+		}
 	}
 }
 
+// WithRateLimit 按 cfg 中租户的额度限制请求速率，默认不限流
+func WithRateLimit(limiter ratelimit.Limiter, cfg conf.RateLimitConfig) ClientOption {
+	return func(o *clientOptions) {
+		o.limiter = limiter
+		o.rateLimit = cfg
+	}
+}
+
+// NewClient 创建飞书客户端；同一应用应复用客户端，见 Pool
+func NewClient(appID, appSecret, domain string, opts ...ClientOption) *Client {
+	options := &clientOptions{
+		openBaseURL: "https://open." + domain,
+		tenant:      domain,
+		transport:   http.DefaultTransport,
+		timeout:     60 * time.Second,
+		retry:       DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	larkOptions := []lark.ClientOptionFunc{
+		lark.WithAppCredential(appID, appSecret),
+		lark.WithOpenBaseURL(options.openBaseURL),
+		lark.WithTimeout(options.timeout),
+		lark.WithNetHttpClient(&http.Client{
+			Timeout:   options.timeout,
+			Transport: &metricsTransport{tenant: options.tenant, next: options.transport},
+		}),
+	}
+	if options.store != nil {
+		larkOptions = append(larkOptions, lark.WithStore(options.store))
+	}
+	// 重试在最外层，按 classifyErrors 分类后的错误判断是否重试；限流在重试之内，每次重试同样消耗额度
+	middlewares := []lark.ApiMiddleware{(&retryer{policy: options.retry, tenant: options.tenant}).middleware}
+	if options.limiter != nil {
+		t := &throttle{limiter: options.limiter, appID: appID, limits: budgets(options.rateLimit, options.tenant)}
+		middlewares = append(middlewares, t.middleware)
+	}
+	larkOptions = append(larkOptions, lark.WithApiMiddleware(append(middlewares, classifyErrors)...))
+	return &Client{client: lark.New(larkOptions...)}
+}
+
 func (c *Client) GetWikiNodeInfo(ctx context.Context, token string, UserAccessToken string) (*lark.GetWikiNodeRespNode, error) {
 
 	var resp *lark.GetWikiNodeResp
@@ -70,8 +167,24 @@ func (c *Client) DownloadFile(fileToken, userAccessToken string) (*lark.Download
 
 // GetDocumentContent 获取文档内容
 func (c *Client) GetDocumentContent(ctx context.Context, docToken, userAccessToken string) (*model.DocContentResult, error) { //获取真实文件数据
+	revision, err := c.GetDocumentRevision(ctx, docToken, userAccessToken, 0)
+	if err != nil {
+		return nil, err
+	}
+	return revision.Content, nil
+}
+
+// DocumentRevision 文档某一版本的转换结果
+type DocumentRevision struct {
+	Content *model.DocContentResult
+	// Blocks 为飞书返回的原始块（不含表格展开时生成的块），用于块级别对比
+	Blocks []*lark.DocxBlock
+}
+
+// GetDocumentRevision 获取文档指定版本的内容，revisionID <= 0 表示最新版本
+func (c *Client) GetDocumentRevision(ctx context.Context, docToken, userAccessToken string, revisionID int64) (*DocumentRevision, error) {
 	// 1. 获取基础文档内容
-	docx, blocks, tittle, err := c.GetDocxContent(ctx, docToken, userAccessToken)
+	docx, blocks, tittle, err := c.GetDocxContentAtRevision(ctx, docToken, userAccessToken, revisionID)
 	if err != nil {
 		return nil, fmt.Errorf("获取文档内容失败: %w", err)
 	}
@@ -79,6 +192,7 @@ func (c *Client) GetDocumentContent(ctx context.Context, docToken, userAccessTok
 	if len(blocks) == 0 {
 		return nil, fmt.Errorf("文档内容为空")
 	}
+	rawBlocks := append([]*lark.DocxBlock(nil), blocks...)
 
 	// 3. 构建块索引映射
 	indexMap := make(map[string]int)
@@ -102,6 +216,12 @@ func (c *Client) GetDocumentContent(ctx context.Context, docToken, userAccessTok
 	sortChildrenByIndex(blocks[0].Children, indexMap)
 
 	// 6. 处理表格块
+	tables, expanded := 0, 0
+	for _, block := range blocks {
+		if block != nil && isTableBlock(block) {
+			tables++
+		}
+	}
 	for n := 0; n < len(blocks); n++ {
 		block := blocks[n]
 		if block == nil {
@@ -113,6 +233,8 @@ func (c *Client) GetDocumentContent(ctx context.Context, docToken, userAccessTok
 			if err != nil {
 				return nil, err
 			}
+			expanded++
+			progress.Report(ctx, progress.Event{Stage: progress.StageTable, Done: expanded, Total: tables})
 
 			// 插入新生成的块
 			blocks = insertBlocks(blocks, n+1, newBlocks)
@@ -130,22 +252,31 @@ func (c *Client) GetDocumentContent(ctx context.Context, docToken, userAccessTok
 	docTitle := tittle // 这里可以根据文档结构获取标题，或是从其它源提取
 
 	// 返回文档内容和标题
-	return &model.DocContentResult{
-		Markdown:  markdown,
-		DocTitle:  docTitle,
-		ImgTokens: imgTokens,
+	return &DocumentRevision{
+		Content: &model.DocContentResult{
+			Markdown:   markdown,
+			DocTitle:   docTitle,
+			ImgTokens:  imgTokens,
+			RevisionID: docx.RevisionID,
+		},
+		Blocks: rawBlocks,
 	}, nil
 }
 
 func parseDocxContent(docx *lark.DocxDocument, blocks []*lark.DocxBlock) (string, []string) {
-	cfg := config.LoadConfig()
-	newConfig := core.NewConfig(cfg.Feishu.AppID, cfg.Feishu.AppSecret)
+	// 解析只用到输出配置，无需读取应用凭证
+	newConfig := core.NewConfig("", "")
 	parser := core.NewParser(newConfig.Output)
 	return parser.ParseDocxContent(docx, blocks), parser.ImgTokens
 }
 
 // GetDocxContent 获取普通文档内容
 func (c *Client) GetDocxContent(ctx context.Context, docToken, userAccessToken string) (*lark.DocxDocument, []*lark.DocxBlock, string, error) {
+	return c.GetDocxContentAtRevision(ctx, docToken, userAccessToken, 0)
+}
+
+// GetDocxDocument 获取普通文档的标题与最新版本号，不获取文档块
+func (c *Client) GetDocxDocument(ctx context.Context, docToken, userAccessToken string) (*lark.DocxDocument, error) {
 	// 创建请求
 	req := &lark.GetDocxDocumentReq{
 		DocumentID: docToken,
@@ -161,24 +292,42 @@ func (c *Client) GetDocxContent(ctx context.Context, docToken, userAccessToken s
 	}
 
 	if err != nil {
-		return nil, nil, "", err
+		return nil, err
 	}
 
-	docx := &lark.DocxDocument{
+	return &lark.DocxDocument{
 		DocumentID: resp.Document.DocumentID,
 		RevisionID: resp.Document.RevisionID,
 		Title:      resp.Document.Title,
+	}, nil
+}
+
+// GetDocxContentAtRevision 获取普通文档指定版本的内容，revisionID <= 0 表示最新版本
+func (c *Client) GetDocxContentAtRevision(ctx context.Context, docToken, userAccessToken string, revisionID int64) (*lark.DocxDocument, []*lark.DocxBlock, string, error) {
+	docx, err := c.GetDocxDocument(ctx, docToken, userAccessToken)
+	if err != nil {
+		return nil, nil, "", err
 	}
+	if revisionID > 0 {
+		if revisionID > docx.RevisionID {
+			return nil, nil, "", fmt.Errorf("%w: revision %d, latest revision is %d", ErrNotFound, revisionID, docx.RevisionID)
+		}
+		docx.RevisionID = revisionID
+	}
+	progress.Report(ctx, progress.Event{Stage: progress.StageDocument, Title: docx.Title})
 
 	var blocks []*lark.DocxBlock
 	var pageToken *string
 
-	for {
+	for page := 1; ; page++ {
 		// 获取文档的块列表
 		blockReq := &lark.GetDocxBlockListOfDocumentReq{
 			DocumentID: docx.DocumentID,
 			PageToken:  pageToken,
 		}
+		if revisionID > 0 {
+			blockReq.DocumentRevisionID = &revisionID
+		}
 
 		if userAccessToken != "" {
 			resp2, _, err := c.client.Drive.GetDocxBlockListOfDocument(ctx, blockReq, lark.WithUserAccessToken(userAccessToken))
@@ -186,6 +335,7 @@ func (c *Client) GetDocxContent(ctx context.Context, docToken, userAccessToken s
 				return docx, nil, "", err
 			}
 			blocks = append(blocks, resp2.Items...)
+			progress.Report(ctx, progress.Event{Stage: progress.StageBlocks, Page: page, Done: len(blocks)})
 			pageToken = &resp2.PageToken
 			if !resp2.HasMore {
 				break
@@ -196,6 +346,7 @@ func (c *Client) GetDocxContent(ctx context.Context, docToken, userAccessToken s
 				return docx, nil, "", err
 			}
 			blocks = append(blocks, resp2.Items...)
+			progress.Report(ctx, progress.Event{Stage: progress.StageBlocks, Page: page, Done: len(blocks)})
 			pageToken = &resp2.PageToken
 			if !resp2.HasMore {
 				break
@@ -203,7 +354,7 @@ func (c *Client) GetDocxContent(ctx context.Context, docToken, userAccessToken s
 		}
 	}
 
-	return docx, blocks, resp.Document.Title, nil
+	return docx, blocks, docx.Title, nil
 }
 
 func (c *Client) DownloadImageRaw(ctx context.Context, imgToken, imgDir string, userAccessToken string) (string, []byte, error) {
@@ -379,7 +530,7 @@ func (c *Client) GetSheetContent(ctx context.Context, docToken string, userAcces
 	}
 
 	if err != nil {
-		fmt.Println("处理数据时出错: %v", err)
+		fmt.Printf("处理数据时出错: %v\n", err)
 	}
 	return rowCount, colCount, flatValues, merges, err
 }
@@ -415,24 +566,13 @@ func (c *Client) GetBitableContent(ctx context.Context, bitableToken string, use
 	// 行数
 	rowCount := resp.Total + 1
 
-	// 查找最大字段数和字段名
-	maxFields := 0
-	var fieldNames []string
-	for _, item := range resp.Items {
-		if item == nil || item.Fields == nil {
-			continue
-		}
-		numFields := len(item.Fields)
-		if numFields > maxFields {
-			maxFields = numFields
-			fieldNames = make([]string, 0, numFields)
-			for k := range item.Fields {
-				fieldNames = append(fieldNames, k)
-			}
-		}
+	// 列顺序与表头取自字段列表，记录中空字段不会出现，不能据此确定列
+	fieldNames, err := c.getBitableFieldNames(ctx, parts[0], parts[1], viewID, userAccessToken)
+	if err != nil {
+		return 0, 0, nil, err
 	}
 
-	colCount := int64(maxFields)
+	colCount := int64(len(fieldNames))
 	if colCount == 0 {
 		return rowCount, colCount, nil, fmt.Errorf("no fields found in any item")
 	}
@@ -457,6 +597,40 @@ func (c *Client) GetBitableContent(ctx context.Context, bitableToken string, use
 	return rowCount, colCount, flatValues, nil
 }
 
+// getBitableFieldNames 按多维表格中的顺序返回字段名，指定视图时跳过该视图隐藏的字段
+func (c *Client) getBitableFieldNames(ctx context.Context, appToken, tableID, viewID, userAccessToken string) ([]string, error) {
+	pageSize := int64(100)
+	req := &lark.GetBitableFieldListReq{
+		AppToken: appToken,
+		TableID:  tableID,
+		PageSize: &pageSize,
+	}
+	if viewID != "" {
+		req.ViewID = &viewID
+	}
+	var options []lark.MethodOptionFunc
+	if userAccessToken != "" {
+		options = append(options, lark.WithUserAccessToken(userAccessToken))
+	}
+
+	var fieldNames []string
+	for {
+		resp, _, err := c.client.Bitable.GetBitableFieldList(ctx, req, options...)
+		if err != nil {
+			return nil, fmt.Errorf("获取多维表格字段失败: %w", err)
+		}
+		for _, item := range resp.Items {
+			if item != nil && !item.IsHidden {
+				fieldNames = append(fieldNames, item.FieldName)
+			}
+		}
+		if !resp.HasMore {
+			return fieldNames, nil
+		}
+		req.PageToken = &resp.PageToken
+	}
+}
+
 func (c *Client) CreateTable(i int64, j int64, flatValues []string, merges []*lark.GetSheetRespSheetMerge, docToken string) ([]*lark.DocxBlock, error) {
 	// 存储最终的块数组
 	var blocksArray []*lark.DocxBlock
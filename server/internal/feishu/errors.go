@@ -0,0 +1,137 @@
+package feishu
+
+import (
+	"context"
+	"errors"
+	"feishu2md/server/internal/ratelimit"
+	"github.com/chyroc/lark"
+	"net/http"
+)
+
+// 开放平台错误的分类，可用 errors.Is 判断，如 errors.Is(err, feishu.ErrNotFound)
+var (
+	ErrNoPermission = errors.New("feishu: no permission")
+	ErrNotFound     = errors.New("feishu: not found")
+	ErrRateLimited  = errors.New("feishu: rate limited")
+	ErrTokenExpired = errors.New("feishu: access token expired")
+	ErrInvalidToken = errors.New("feishu: invalid access token")
+	ErrServer       = errors.New("feishu: server error")
+)
+
+// codeKinds 开放平台错误码对应的分类，未列出的错误码按 HTTP 状态码分类
+var codeKinds = map[int64]error{
+	// 通用
+	99991661: ErrInvalidToken, // 缺少访问令牌
+	99991663: ErrInvalidToken, // tenant_access_token 无效
+	99991664: ErrInvalidToken, // app_access_token 无效
+	99991668: ErrInvalidToken, // user_access_token 无效
+	99991677: ErrTokenExpired, // user_access_token 已过期
+	99991672: ErrNoPermission, // 应用未开通所需权限
+	99991679: ErrNoPermission, // 用户未授权所需权限
+	99991400: ErrRateLimited,  // 请求频率超限
+	// 云文档
+	1770002: ErrNotFound,
+	1770032: ErrNoPermission,
+	1061004: ErrNoPermission,
+	1061007: ErrNotFound,
+	// 知识库
+	131005: ErrNotFound,
+	131006: ErrNoPermission,
+	// 电子表格
+	91402: ErrNotFound,
+	91403: ErrNoPermission,
+	// 多维表格
+	1254040: ErrNotFound,
+	1254302: ErrNoPermission,
+}
+
+// statusKinds HTTP 状态码对应的分类
+var statusKinds = map[int]error{
+	http.StatusUnauthorized:    ErrInvalidToken,
+	http.StatusForbidden:       ErrNoPermission,
+	http.StatusNotFound:        ErrNotFound,
+	http.StatusTooManyRequests: ErrRateLimited,
+}
+
+// Error 开放平台返回的错误，errors.Is 可匹配其分类，errors.As 可取得 SDK 的原始错误
+type Error struct {
+	// Kind 错误分类，无法归类时为 nil
+	Kind error
+	// Code 开放平台错误码，未返回 JSON 时为 0
+	Code int64
+	// Status HTTP 状态码，未收到响应时为 0
+	Status int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// classify 按错误码与 HTTP 状态码为 SDK 返回的错误分类，网络错误与上下文取消原样返回
+func classify(err error, response *lark.Response) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	var typed *Error
+	if errors.As(err, &typed) {
+		return err
+	}
+	e := &Error{Err: err}
+	if response != nil {
+		e.Status = response.StatusCode
+	}
+	var larkErr *lark.Error
+	if errors.As(err, &larkErr) {
+		e.Code = larkErr.Code
+		e.Kind = codeKinds[larkErr.Code]
+	}
+	if e.Kind == nil {
+		e.Kind = statusKinds[e.Status]
+	}
+	if e.Kind == nil && e.Status >= http.StatusInternalServerError {
+		e.Kind = ErrServer
+	}
+	if e.Code == 0 && e.Status == 0 {
+		return err
+	}
+	return e
+}
+
+// classifyErrors 为每个开放平台请求的错误分类的 SDK 中间件
+func classifyErrors(next lark.ApiEndpoint) lark.ApiEndpoint {
+	return func(ctx context.Context, req *lark.RawRequestReq, resp interface{}) (*lark.Response, error) {
+		response, err := next(ctx, req, resp)
+		return response, classify(err, response)
+	}
+}
+
+// Retryable 错误是否为限流或服务端错误，稍后重试可能成功
+func Retryable(err error) bool {
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrServer)
+}
+
+// HTTPStatus 返回错误对应的 HTTP 状态码，未归类的错误为 500
+func HTTPStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNoPermission):
+		return http.StatusForbidden
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrRateLimited), errors.Is(err, ratelimit.ErrWaitExceeded):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrTokenExpired), errors.Is(err, ErrInvalidToken):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrServer):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
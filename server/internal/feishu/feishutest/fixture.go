@@ -0,0 +1,129 @@
+// Package feishutest 提供本地的飞书开放平台模拟服务，按录制的 fixture 返回响应，
+// 用于在不访问 https://open.<domain> 的情况下验证完整的转换流程。
+package feishutest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Fixture 一次请求与对应的响应
+type Fixture struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Query 需要匹配的查询参数，未列出的参数不参与匹配
+	Query  map[string]string `json:"query,omitempty"`
+	Status int               `json:"status,omitempty"` // 为空时返回 200
+	Header map[string]string `json:"header,omitempty"`
+	Body   json.RawMessage   `json:"body,omitempty"`
+	// BodyFile 非 JSON 响应（如图片）的内容文件，相对于 fixture 所在目录
+	BodyFile string `json:"body_file,omitempty"`
+
+	// dir fixture 文件所在目录，用于读取 BodyFile
+	dir string
+}
+
+// match 判断请求是否命中 fixture，返回命中的查询参数个数，未命中时返回 -1
+func (f *Fixture) match(method, urlPath string, query url.Values) int {
+	if !strings.EqualFold(f.Method, method) || f.Path != urlPath {
+		return -1
+	}
+	for key, value := range f.Query {
+		if query.Get(key) != value {
+			return -1
+		}
+	}
+	return len(f.Query)
+}
+
+func (f *Fixture) key() string {
+	return fmt.Sprintf("%s %s %v", strings.ToUpper(f.Method), f.Path, f.Query)
+}
+
+// LoadFixtures 读取目录下所有的 *.json fixture，单个文件可以是一个 fixture 或 fixture 数组
+func LoadFixtures(fsys fs.FS) ([]*Fixture, error) {
+	var fixtures []*Fixture
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(name) != ".json" {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		var items []*Fixture
+		if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '[' {
+			err = json.Unmarshal(data, &items)
+		} else {
+			item := new(Fixture)
+			err = json.Unmarshal(data, item)
+			items = []*Fixture{item}
+		}
+		if err != nil {
+			return fmt.Errorf("解析 fixture %s 失败: %w", name, err)
+		}
+		for _, item := range items {
+			if item.Method == "" || item.Path == "" {
+				return fmt.Errorf("fixture %s 缺少 method 或 path", name)
+			}
+			item.dir = path.Dir(name)
+			fixtures = append(fixtures, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fixtures, nil
+}
+
+// write 按 fixture 输出响应
+func (f *Fixture) write(w http.ResponseWriter, fsys fs.FS) {
+	body := []byte(f.Body)
+	if f.BodyFile != "" {
+		if fsys == nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("读取 %s 失败: 未指定 fixture 目录", f.BodyFile))
+			return
+		}
+		data, err := fs.ReadFile(fsys, path.Join(f.dir, f.BodyFile))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("读取 %s 失败: %v", f.BodyFile, err))
+			return
+		}
+		body = data
+	}
+
+	header := w.Header()
+	for key, value := range f.Header {
+		header.Set(key, value)
+	}
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+	status := f.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// writeError 按开放平台的格式返回错误
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"code": status,
+		"msg":  msg,
+	})
+}
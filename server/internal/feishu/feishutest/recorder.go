@@ -0,0 +1,155 @@
+package feishutest
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// recordHeaders 录制时保留的响应头
+var recordHeaders = []string{"Content-Type", "Content-Disposition"}
+
+// Recorder 将请求转发到真实的开放平台，并把响应保存为 fixture
+type Recorder struct {
+	proxy *httputil.ReverseProxy
+	dir   string
+
+	mu       sync.Mutex
+	recorded []string
+}
+
+// NewRecorder 创建转发到 target（如 https://open.feishu.cn）的录制器，fixture 写入 dir
+func NewRecorder(target, dir string) (*Recorder, error) {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target %q: %w", target, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	rec := &Recorder{dir: dir}
+	rec.proxy = httputil.NewSingleHostReverseProxy(targetURL)
+	director := rec.proxy.Director
+	rec.proxy.Director = func(r *http.Request) {
+		director(r)
+		r.Host = targetURL.Host
+		// 录制需要未压缩的响应
+		r.Header.Del("Accept-Encoding")
+	}
+	rec.proxy.ModifyResponse = rec.record
+	return rec, nil
+}
+
+func (rec *Recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec.proxy.ServeHTTP(w, r)
+}
+
+// Recorded 返回已写入的 fixture 文件
+func (rec *Recorder) Recorded() []string {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]string(nil), rec.recorded...)
+}
+
+// record 保存响应，令牌接口包含凭证，不做录制
+func (rec *Recorder) record(resp *http.Response) error {
+	req := resp.Request
+	if isTokenRequest(req.URL.Path) || strings.HasPrefix(req.URL.Path, "/open-apis/authen/") {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	fixture := &Fixture{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Status: resp.StatusCode,
+		Header: map[string]string{},
+	}
+	for key, values := range req.URL.Query() {
+		if fixture.Query == nil {
+			fixture.Query = map[string]string{}
+		}
+		fixture.Query[key] = values[0]
+	}
+	for _, key := range recordHeaders {
+		if value := resp.Header.Get(key); value != "" {
+			fixture.Header[key] = value
+		}
+	}
+
+	name := fixtureName(req)
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "application/json" && json.Valid(body) {
+		indented := new(bytes.Buffer)
+		if err := json.Indent(indented, body, "", "  "); err != nil {
+			return err
+		}
+		fixture.Body = indented.Bytes()
+	} else {
+		fixture.BodyFile = filepath.ToSlash(filepath.Join("media", name+".bin"))
+		if err := os.MkdirAll(filepath.Join(rec.dir, "media"), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(rec.dir, fixture.BodyFile), body, 0644); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+	file := filepath.Join(rec.dir, name+".json")
+	if err := os.WriteFile(file, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+
+	rec.mu.Lock()
+	rec.recorded = append(rec.recorded, file)
+	rec.mu.Unlock()
+	return nil
+}
+
+// fixtureName 由请求方法、路径与查询参数生成文件名，查询参数不同的请求（如分页）保存为不同文件
+func fixtureName(r *http.Request) string {
+	name := strings.ToLower(r.Method) + strings.ReplaceAll(strings.TrimPrefix(r.URL.Path, "/open-apis"), "/", "_")
+	if r.URL.RawQuery != "" {
+		sum := sha1.Sum([]byte(r.URL.Query().Encode()))
+		name += "_" + hex.EncodeToString(sum[:4])
+	}
+	return name
+}
+
+// RecordingServer 运行在本地端口上的录制代理
+type RecordingServer struct {
+	*httptest.Server
+	*Recorder
+}
+
+// NewRecordingServer 启动转发到 target 的录制代理，使用完毕后需调用 Close
+func NewRecordingServer(target, dir string) (*RecordingServer, error) {
+	rec, err := NewRecorder(target, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &RecordingServer{Server: httptest.NewServer(rec), Recorder: rec}, nil
+}
@@ -0,0 +1,161 @@
+package feishutest
+
+import (
+	"embed"
+	"encoding/json"
+	"feishu2md/server/internal/feishu"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+)
+
+const (
+	// TenantAccessToken 模拟服务签发的 tenant_access_token
+	TenantAccessToken = "t-feishutest"
+	AppID             = "cli_feishutest"
+	AppSecret         = "feishutest-secret"
+)
+
+//go:embed fixtures
+var embedded embed.FS
+
+// SampleFixtures 内置的示例 fixture，包含文档、知识库、电子表格、多维表格与图片
+func SampleFixtures() fs.FS {
+	sub, err := fs.Sub(embedded, "fixtures/sample")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// Request 模拟服务收到的请求
+type Request struct {
+	Method        string
+	Path          string
+	RawQuery      string
+	Authorization string
+}
+
+// Handler 按 fixture 响应开放平台请求的 http.Handler
+type Handler struct {
+	fsys fs.FS
+
+	mu        sync.Mutex
+	fixtures  []*Fixture
+	requests  []Request
+	unmatched []string
+}
+
+// NewHandler 从 fsys 加载 fixture 并创建 Handler，fsys 为 nil 时不加载，可通过 Add 添加
+func NewHandler(fsys fs.FS) (*Handler, error) {
+	if fsys == nil {
+		return &Handler{}, nil
+	}
+	fixtures, err := LoadFixtures(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{fsys: fsys, fixtures: fixtures}, nil
+}
+
+// Add 追加 fixture，后追加的 fixture 在匹配程度相同时优先
+func (h *Handler) Add(fixtures ...*Fixture) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.fixtures = append(h.fixtures, fixtures...)
+}
+
+// Requests 返回收到的全部请求
+func (h *Handler) Requests() []Request {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Request(nil), h.requests...)
+}
+
+// Unmatched 返回没有命中任何 fixture 的请求
+func (h *Handler) Unmatched() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.unmatched...)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	h.mu.Lock()
+	h.requests = append(h.requests, Request{
+		Method:        r.Method,
+		Path:          r.URL.Path,
+		RawQuery:      r.URL.RawQuery,
+		Authorization: r.Header.Get("Authorization"),
+	})
+	var matched *Fixture
+	best := -1
+	for _, fixture := range h.fixtures {
+		if score := fixture.match(r.Method, r.URL.Path, query); score >= best && score >= 0 {
+			matched, best = fixture, score
+		}
+	}
+	if matched == nil && !isTokenRequest(r.URL.Path) {
+		h.unmatched = append(h.unmatched, r.Method+" "+r.URL.RequestURI())
+	}
+	h.mu.Unlock()
+
+	switch {
+	case matched != nil:
+		matched.write(w, h.fsys)
+	case isTokenRequest(r.URL.Path):
+		writeToken(w)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("feishutest: no fixture for %s %s", r.Method, r.URL.RequestURI()))
+	}
+}
+
+// tokenPaths 未提供 fixture 时由模拟服务直接签发的令牌接口
+var tokenPaths = map[string]bool{
+	"/open-apis/auth/v3/tenant_access_token/internal": true,
+	"/open-apis/auth/v3/app_access_token/internal":    true,
+}
+
+func isTokenRequest(path string) bool {
+	return tokenPaths[path]
+}
+
+func writeToken(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"code":                0,
+		"msg":                 "ok",
+		"tenant_access_token": TenantAccessToken,
+		"app_access_token":    TenantAccessToken,
+		"expire":              7200,
+	})
+}
+
+// Server 运行在本地端口上的模拟开放平台
+type Server struct {
+	*httptest.Server
+	*Handler
+}
+
+// NewServer 使用 fsys 中的 fixture 启动模拟服务，使用完毕后需调用 Close
+func NewServer(fsys fs.FS) (*Server, error) {
+	handler, err := NewHandler(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{Server: httptest.NewServer(handler), Handler: handler}, nil
+}
+
+// NewServerFromDir 使用目录中的 fixture 启动模拟服务
+func NewServerFromDir(dir string) (*Server, error) {
+	return NewServer(os.DirFS(dir))
+}
+
+// NewClient 创建指向模拟服务的飞书客户端
+func (s *Server) NewClient() *feishu.Client {
+	return feishu.NewClient(AppID, AppSecret, "feishu.cn", feishu.WithOpenBaseURL(s.URL))
+}
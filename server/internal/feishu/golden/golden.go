@@ -0,0 +1,229 @@
+// Package golden 使用 feishutest 模拟服务运行转换用例，并将生成的 Markdown 与 golden 文件对比。
+//
+// 每个用例是语料目录下的一个子目录：
+//
+//	case.json    用例说明（类型、token、文档链接等）
+//	blocks.json  可选，docx 文档的块列表（获取文档所有块接口返回的 items）
+//	fixtures/    可选，feishutest 格式的其他接口响应（嵌入的电子表格、多维表格、知识库节点等）
+//	expected.md  golden 文件
+//
+// 用例随 go test 运行：
+//
+//	go test ./internal/feishu/golden                          # 检查
+//	go test ./internal/feishu/golden -update                  # 重新生成 golden 文件
+//	go test ./internal/feishu/golden -run TestGolden/lists    # 只运行名称匹配 lists 的用例
+package golden
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"feishu2md/server/internal/feishu"
+	"feishu2md/server/internal/feishu/feishutest"
+	"feishu2md/server/internal/service/revision"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	CaseFile     = "case.json"
+	BlocksFile   = "blocks.json"
+	FixturesDir  = "fixtures"
+	ExpectedFile = "expected.md"
+)
+
+// Case 一个转换用例
+type Case struct {
+	Name string `json:"-"`
+	Dir  string `json:"-"`
+
+	Description string `json:"description"`
+	// Kind 文档类型：docx / sheet / bitable / wiki
+	Kind  string `json:"kind"`
+	Token string `json:"token"`
+	// URL 电子表格与多维表格用例的文档链接，用于解析 sheet/table/view 参数
+	URL string `json:"url,omitempty"`
+	// Title/RevisionID docx 用例的文档信息
+	Title      string `json:"title,omitempty"`
+	RevisionID int64  `json:"revision_id,omitempty"`
+}
+
+// Result 用例的运行结果
+type Result struct {
+	Case     *Case
+	Markdown string
+	// Diff 与 golden 文件的差异，为空表示一致
+	Diff    string
+	Updated bool
+	Err     error
+}
+
+// Passed 用例是否通过
+func (r *Result) Passed() bool {
+	return r.Err == nil && r.Diff == ""
+}
+
+// LoadCases 读取语料目录下的全部用例，按名称排序
+func LoadCases(dir string) ([]*Case, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var cases []*Case
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		caseDir := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(filepath.Join(caseDir, CaseFile))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		c := &Case{Name: entry.Name(), Dir: caseDir}
+		if err := json.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("解析用例 %s 失败: %w", entry.Name(), err)
+		}
+		cases = append(cases, c)
+	}
+	sort.Slice(cases, func(i, j int) bool { return cases[i].Name < cases[j].Name })
+	return cases, nil
+}
+
+// Run 运行单个用例
+func Run(ctx context.Context, c *Case, update bool) *Result {
+	result := &Result{Case: c}
+
+	server, err := newServer(c)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer server.Close()
+
+	result.Markdown, err = convert(ctx, server.NewClient(), c)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if unmatched := server.Unmatched(); len(unmatched) > 0 {
+		result.Err = fmt.Errorf("缺少 fixture: %s", strings.Join(unmatched, ", "))
+		return result
+	}
+
+	expectedPath := filepath.Join(c.Dir, ExpectedFile)
+	if update {
+		result.Err = os.WriteFile(expectedPath, []byte(result.Markdown), 0644)
+		result.Updated = result.Err == nil
+		return result
+	}
+	expected, err := os.ReadFile(expectedPath)
+	if err != nil {
+		result.Err = fmt.Errorf("读取 golden 文件失败: %w", err)
+		return result
+	}
+	if string(expected) != result.Markdown {
+		result.Diff = revision.UnifiedDiff(string(expected), result.Markdown, ExpectedFile, "actual")
+	}
+	return result
+}
+
+// newServer 根据用例的 fixture 与块列表启动模拟服务
+func newServer(c *Case) (*feishutest.Server, error) {
+	var fixtures fs.FS
+	if info, err := os.Stat(filepath.Join(c.Dir, FixturesDir)); err == nil && info.IsDir() {
+		fixtures = os.DirFS(filepath.Join(c.Dir, FixturesDir))
+	}
+	server, err := feishutest.NewServer(fixtures)
+	if err != nil {
+		return nil, fmt.Errorf("加载 fixture 失败: %w", err)
+	}
+
+	blocks, err := os.ReadFile(filepath.Join(c.Dir, BlocksFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return server, nil
+	}
+	if err != nil {
+		server.Close()
+		return nil, err
+	}
+	docToken := c.Token
+	if c.Kind == "wiki" {
+		// 知识库用例的块列表属于节点对应的文档，从 fixture 中的节点信息获取
+		node, err := server.NewClient().GetWikiNodeInfo(context.Background(), c.Token, "")
+		if err != nil {
+			server.Close()
+			return nil, fmt.Errorf("获取知识库节点失败: %w", err)
+		}
+		docToken = node.ObjToken
+	}
+	server.Add(docxFixtures(docToken, c.Title, c.RevisionID, blocks)...)
+	return server, nil
+}
+
+// docxFixtures 由块列表生成获取文档信息与获取文档所有块两个接口的 fixture
+func docxFixtures(docToken, title string, revisionID int64, blocks json.RawMessage) []*feishutest.Fixture {
+	if revisionID == 0 {
+		revisionID = 1
+	}
+	document, _ := json.Marshal(map[string]interface{}{
+		"code": 0,
+		"msg":  "success",
+		"data": map[string]interface{}{
+			"document": map[string]interface{}{
+				"document_id": docToken,
+				"revision_id": revisionID,
+				"title":       title,
+			},
+		},
+	})
+	blockList, _ := json.Marshal(map[string]interface{}{
+		"code": 0,
+		"msg":  "success",
+		"data": map[string]interface{}{
+			"items":    blocks,
+			"has_more": false,
+		},
+	})
+	return []*feishutest.Fixture{
+		{Method: "GET", Path: "/open-apis/docx/v1/documents/" + docToken, Body: document},
+		{Method: "GET", Path: "/open-apis/docx/v1/documents/" + docToken + "/blocks", Body: blockList},
+	}
+}
+
+// convert 按用例类型调用对应的转换流程
+func convert(ctx context.Context, client *feishu.Client, c *Case) (string, error) {
+	kind, token := c.Kind, c.Token
+	if kind == "wiki" {
+		node, err := client.GetWikiNodeInfo(ctx, token, "")
+		if err != nil {
+			return "", fmt.Errorf("获取知识库节点失败: %w", err)
+		}
+		kind, token = node.ObjType, node.ObjToken
+	}
+
+	switch kind {
+	case "docx":
+		content, err := client.GetDocumentContent(ctx, token, "")
+		if err != nil {
+			return "", err
+		}
+		return content.Markdown, nil
+	case "sheet":
+		content, err := client.GetSheetsContent(ctx, token, "", c.URL)
+		if err != nil {
+			return "", err
+		}
+		return content.Markdown, nil
+	case "bitable":
+		return client.GetBitablesContent(ctx, token, "", c.URL)
+	default:
+		return "", fmt.Errorf("不支持的用例类型 %q", kind)
+	}
+}
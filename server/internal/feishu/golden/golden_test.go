@@ -0,0 +1,30 @@
+package golden
+
+import (
+	"context"
+	"flag"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "用生成结果覆盖 golden 文件")
+
+// TestGolden 运行 testdata 下的全部用例，-run TestGolden/<用例名> 只运行单个用例
+func TestGolden(t *testing.T) {
+	cases, err := LoadCases("testdata")
+	if err != nil {
+		t.Fatalf("加载语料失败: %v", err)
+	}
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			result := Run(context.Background(), c, *update)
+			switch {
+			case result.Err != nil:
+				t.Fatal(result.Err)
+			case result.Updated:
+				t.Logf("updated %s", ExpectedFile)
+			case result.Diff != "":
+				t.Errorf("输出与 %s 不一致:\n%s", ExpectedFile, result.Diff)
+			}
+		})
+	}
+}
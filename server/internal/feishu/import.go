@@ -0,0 +1,419 @@
+package feishu
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/chyroc/lark"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// 单次创建子块的最大数量
+	maxCreateChildren = 50
+	// 飞书素材上传的大小上限
+	maxImageSize = 20 << 20
+	// 创建表格时的最大行列数，超出部分创建后再插入
+	maxTableCreateSize = 9
+	// 下载一张外部图片的超时时间
+	imageFetchTimeout = 30 * time.Second
+)
+
+// imageClient 下载 Markdown 中的外部图片，只连接公网地址，避免通过导入访问内网与云服务器元数据服务；
+// 不使用环境变量中的代理，否则检查的是代理地址而不是图片地址
+var imageClient = &http.Client{
+	Timeout: imageFetchTimeout,
+	Transport: &http.Transport{
+		DialContext:           (&net.Dialer{Timeout: 10 * time.Second, Control: publicAddressOnly}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+	},
+}
+
+// sharedAddressSpace 运营商级 NAT 地址段，部分云厂商的元数据服务位于其中
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddressOnly 拒绝连接回环、内网、链路本地等非公网地址，在域名解析之后检查，重定向同样生效
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("不允许访问非公网地址 %s", ip)
+	}
+	return nil
+}
+
+// ImportOptions Markdown 导入参数
+type ImportOptions struct {
+	// DocumentID 为空时新建文档，否则替换该文档的全部内容
+	DocumentID      string
+	FolderToken     string
+	Title           string
+	UserAccessToken string
+}
+
+// ImportResult Markdown 导入结果
+type ImportResult struct {
+	DocumentID string
+	RevisionID int64
+	BlockCount int
+	Warnings   []string
+}
+
+// importer 单次导入过程的状态
+type importer struct {
+	client     *Client
+	documentID string
+	opts       []lark.MethodOptionFunc
+	result     *ImportResult
+	images     map[*ImportBlock]*importImage
+}
+
+type importImage struct {
+	name string
+	data []byte
+}
+
+// ImportMarkdown 将 Markdown 写入飞书文档
+func (c *Client) ImportMarkdown(ctx context.Context, markdown []byte, options ImportOptions) (*ImportResult, error) {
+	blocks, warnings := ParseMarkdown(markdown)
+
+	// 未指定标题时使用开头的一级标题
+	title := options.Title
+	if title == "" && len(blocks) > 0 && blocks[0].Block.Heading1 != nil {
+		title = plainText(blocks[0].Block)
+		blocks = blocks[1:]
+	}
+
+	imp := &importer{
+		client: c,
+		opts:   []lark.MethodOptionFunc{lark.WithUserAccessToken(options.UserAccessToken)},
+		result: &ImportResult{Warnings: warnings},
+		images: make(map[*ImportBlock]*importImage),
+	}
+	blocks = imp.loadImages(ctx, blocks)
+
+	if options.DocumentID == "" {
+		req := &lark.CreateDocxReq{}
+		if options.FolderToken != "" {
+			req.FolderToken = &options.FolderToken
+		}
+		if title != "" {
+			req.Title = &title
+		}
+		resp, _, err := c.client.Drive.CreateDocx(ctx, req, imp.opts...)
+		if err != nil {
+			return nil, fmt.Errorf("创建文档失败: %w", err)
+		}
+		imp.documentID = resp.Document.DocumentID
+		imp.result.RevisionID = resp.Document.RevisionID
+	} else {
+		imp.documentID = options.DocumentID
+		if err := imp.clearDocument(ctx, title); err != nil {
+			return nil, err
+		}
+	}
+	imp.result.DocumentID = imp.documentID
+
+	if err := imp.createChildren(ctx, imp.documentID, blocks, 0); err != nil {
+		return imp.result, err
+	}
+	return imp.result, nil
+}
+
+// clearDocument 删除文档根节点下的全部块，并更新标题
+func (imp *importer) clearDocument(ctx context.Context, title string) error {
+	resp, _, err := imp.client.client.Drive.GetDocxBlock(ctx, &lark.GetDocxBlockReq{
+		DocumentID: imp.documentID,
+		BlockID:    imp.documentID,
+	}, imp.opts...)
+	if err != nil {
+		return fmt.Errorf("获取文档失败: %w", err)
+	}
+
+	if count := int64(len(resp.Block.Children)); count > 0 {
+		deleteResp, _, err := imp.client.client.Drive.BatchDeleteDocxBlock(ctx, &lark.BatchDeleteDocxBlockReq{
+			DocumentID: imp.documentID,
+			BlockID:    imp.documentID,
+			StartIndex: 0,
+			EndIndex:   count,
+		}, imp.opts...)
+		if err != nil {
+			return fmt.Errorf("清空文档失败: %w", err)
+		}
+		imp.result.RevisionID = deleteResp.DocumentRevisionID
+	}
+
+	if title != "" {
+		updateResp, _, err := imp.client.client.Drive.UpdateDocxBlock(ctx, &lark.UpdateDocxBlockReq{
+			DocumentID: imp.documentID,
+			BlockID:    imp.documentID,
+			UpdateTextElements: &lark.UpdateDocxBlockReqUpdateTextElements{
+				Elements: []*lark.DocxTextElement{newTextRun(title, lark.DocxTextElementStyle{})},
+			},
+		}, imp.opts...)
+		if err != nil {
+			return fmt.Errorf("更新文档标题失败: %w", err)
+		}
+		imp.result.RevisionID = updateResp.DocumentRevisionID
+	}
+	return nil
+}
+
+// createChildren 在 parentID 下从 index 开始按批次创建子块，再递归创建各块的内容
+func (imp *importer) createChildren(ctx context.Context, parentID string, blocks []*ImportBlock, index int64) error {
+	for start := 0; start < len(blocks); start += maxCreateChildren {
+		batch := blocks[start:min(start+maxCreateChildren, len(blocks))]
+		children := make([]*lark.DocxBlock, 0, len(batch))
+		for _, block := range batch {
+			children = append(children, createBlock(block.Block))
+		}
+		position := index + int64(start)
+		resp, _, err := imp.client.client.Drive.CreateDocxBlock(ctx, &lark.CreateDocxBlockReq{
+			DocumentID: imp.documentID,
+			BlockID:    parentID,
+			Children:   children,
+			Index:      &position,
+		}, imp.opts...)
+		if err != nil {
+			return fmt.Errorf("创建文档块失败: %w", err)
+		}
+		if len(resp.Children) != len(batch) {
+			return fmt.Errorf("创建文档块失败: 期望 %d 个块，实际返回 %d 个", len(batch), len(resp.Children))
+		}
+		imp.result.RevisionID = resp.DocumentRevisionID
+		imp.result.BlockCount += len(batch)
+
+		for i, block := range batch {
+			if err := imp.fillBlock(ctx, resp.Children[i], block); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// createBlock 返回用于创建请求的块，超出限制的表格先按最大行列创建
+func createBlock(block *lark.DocxBlock) *lark.DocxBlock {
+	if block.Table == nil {
+		return block
+	}
+	property := *block.Table.Property
+	property.RowSize = min(property.RowSize, maxTableCreateSize)
+	property.ColumnSize = min(property.ColumnSize, maxTableCreateSize)
+	table := *block
+	table.Table = &lark.DocxBlockTable{Property: &property}
+	return &table
+}
+
+// fillBlock 填充已创建块的图片、表格与子块
+func (imp *importer) fillBlock(ctx context.Context, created *lark.DocxBlock, block *ImportBlock) error {
+	if image, ok := imp.images[block]; ok {
+		return imp.uploadImage(ctx, created.BlockID, image)
+	}
+	if block.Block.Table != nil {
+		return imp.fillTable(ctx, created, block)
+	}
+	if len(block.Children) > 0 {
+		// 高亮块等容器创建时会自带空白子块，写入内容后删除
+		return imp.fillContainer(ctx, created.BlockID, int64(len(created.Children)), block.Children)
+	}
+	return nil
+}
+
+// fillContainer 将内容插入容器块开头，再删除容器中原有的 existing 个子块
+func (imp *importer) fillContainer(ctx context.Context, parentID string, existing int64, children []*ImportBlock) error {
+	if len(children) == 0 {
+		return nil
+	}
+	if err := imp.createChildren(ctx, parentID, children, 0); err != nil {
+		return err
+	}
+	if existing == 0 {
+		return nil
+	}
+	count := int64(len(children))
+	resp, _, err := imp.client.client.Drive.BatchDeleteDocxBlock(ctx, &lark.BatchDeleteDocxBlockReq{
+		DocumentID: imp.documentID,
+		BlockID:    parentID,
+		StartIndex: count,
+		EndIndex:   count + existing,
+	}, imp.opts...)
+	if err != nil {
+		return fmt.Errorf("删除空白块失败: %w", err)
+	}
+	imp.result.RevisionID = resp.DocumentRevisionID
+	return nil
+}
+
+// fillTable 补齐表格行列后逐个填充单元格，每个单元格自带一个空白文本块
+func (imp *importer) fillTable(ctx context.Context, created *lark.DocxBlock, block *ImportBlock) error {
+	if created.Table == nil {
+		return fmt.Errorf("创建表格失败: 未返回表格信息")
+	}
+	property := block.Block.Table.Property
+	cells := created.Table.Cells
+	if int64(len(cells)) != property.RowSize*property.ColumnSize {
+		table, err := imp.resizeTable(ctx, created, property)
+		if err != nil {
+			return err
+		}
+		cells = table.Table.Cells
+	}
+	if len(cells) != len(block.Cells) {
+		return fmt.Errorf("表格单元格数量不匹配: 期望 %d 个，实际 %d 个", len(block.Cells), len(cells))
+	}
+	for i, content := range block.Cells {
+		if err := imp.fillContainer(ctx, cells[i], 1, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resizeTable 插入创建时超出限制的行列，返回更新后的表格块
+func (imp *importer) resizeTable(ctx context.Context, created *lark.DocxBlock, property *lark.DocxBlockTableProperty) (*lark.DocxBlock, error) {
+	table := created
+	for rows := min(property.RowSize, maxTableCreateSize); rows < property.RowSize; rows++ {
+		if err := imp.updateTable(ctx, created.BlockID, &lark.UpdateDocxBlockReq{
+			InsertTableRow: &lark.UpdateDocxBlockReqInsertTableRow{RowIndex: -1},
+		}, &table); err != nil {
+			return nil, err
+		}
+	}
+	for columns := min(property.ColumnSize, maxTableCreateSize); columns < property.ColumnSize; columns++ {
+		if err := imp.updateTable(ctx, created.BlockID, &lark.UpdateDocxBlockReq{
+			InsertTableColumn: &lark.UpdateDocxBlockReqInsertTableColumn{ColumnIndex: -1},
+		}, &table); err != nil {
+			return nil, err
+		}
+	}
+	return table, nil
+}
+
+func (imp *importer) updateTable(ctx context.Context, blockID string, req *lark.UpdateDocxBlockReq, table **lark.DocxBlock) error {
+	req.DocumentID = imp.documentID
+	req.BlockID = blockID
+	resp, _, err := imp.client.client.Drive.UpdateDocxBlock(ctx, req, imp.opts...)
+	if err != nil {
+		return fmt.Errorf("调整表格大小失败: %w", err)
+	}
+	imp.result.RevisionID = resp.DocumentRevisionID
+	*table = resp.Block
+	return nil
+}
+
+// uploadImage 上传图片素材并写入图片块
+func (imp *importer) uploadImage(ctx context.Context, blockID string, image *importImage) error {
+	extra := fmt.Sprintf(`{"drive_route_token":"%s"}`, imp.documentID)
+	uploadResp, _, err := imp.client.client.Drive.UploadDriveMedia(ctx, &lark.UploadDriveMediaReq{
+		FileName:   image.name,
+		ParentType: "docx_image",
+		ParentNode: blockID,
+		Size:       int64(len(image.data)),
+		Extra:      &extra,
+		File:       bytes.NewReader(image.data),
+	}, imp.opts...)
+	if err != nil {
+		return fmt.Errorf("上传图片 %s 失败: %w", image.name, err)
+	}
+
+	resp, _, err := imp.client.client.Drive.UpdateDocxBlock(ctx, &lark.UpdateDocxBlockReq{
+		DocumentID:   imp.documentID,
+		BlockID:      blockID,
+		ReplaceImage: &lark.UpdateDocxBlockReqReplaceImage{Token: uploadResp.FileToken},
+	}, imp.opts...)
+	if err != nil {
+		return fmt.Errorf("设置图片 %s 失败: %w", image.name, err)
+	}
+	imp.result.RevisionID = resp.DocumentRevisionID
+	return nil
+}
+
+// loadImages 在创建文档前下载所有图片，无法获取的图片改为保留原始链接的文本块
+func (imp *importer) loadImages(ctx context.Context, blocks []*ImportBlock) []*ImportBlock {
+	for i, block := range blocks {
+		if block.ImageSource != "" {
+			name, data, err := fetchImage(ctx, block.ImageSource)
+			if err != nil {
+				imp.result.Warnings = append(imp.result.Warnings, fmt.Sprintf("图片 %s 导入失败: %v", block.ImageSource, err))
+				link := lark.DocxTextElementStyle{Link: &lark.DocxTextElementStyleLink{URL: url.QueryEscape(block.ImageSource)}}
+				blocks[i] = &ImportBlock{Block: newTextBlock(lark.DocxBlockTypeText, []*lark.DocxTextElement{newTextRun(block.ImageSource, link)}, nil)}
+				continue
+			}
+			imp.images[block] = &importImage{name: name, data: data}
+		}
+		block.Children = imp.loadImages(ctx, block.Children)
+		for j := range block.Cells {
+			block.Cells[j] = imp.loadImages(ctx, block.Cells[j])
+		}
+	}
+	return blocks
+}
+
+// fetchImage 获取图片内容，支持 http/https 链接与 data URI
+func fetchImage(ctx context.Context, source string) (string, []byte, error) {
+	if strings.HasPrefix(source, "data:") {
+		meta, payload, ok := strings.Cut(strings.TrimPrefix(source, "data:"), ",")
+		if !ok || !strings.HasSuffix(meta, ";base64") {
+			return "", nil, fmt.Errorf("仅支持 base64 编码的 data URI")
+		}
+		data, err := base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(data) > maxImageSize {
+			return "", nil, fmt.Errorf("图片超过 %d MB", maxImageSize>>20)
+		}
+		ext := strings.TrimPrefix(strings.TrimSuffix(meta, ";base64"), "image/")
+		return "image." + ext, data, nil
+	}
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return "", nil, fmt.Errorf("不支持的图片地址，请使用 http/https 链接或 data URI")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	resp, err := imageClient.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("下载失败，状态码 %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageSize+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(data) > maxImageSize {
+		return "", nil, fmt.Errorf("图片超过 %d MB", maxImageSize>>20)
+	}
+
+	name := path.Base(req.URL.Path)
+	if name == "" || name == "/" || name == "." {
+		name = "image"
+	}
+	return name, data, nil
+}
@@ -0,0 +1,400 @@
+package feishu
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/Wsine/feishu2md/core"
+	"github.com/chyroc/lark"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ImportBlock 待创建的块及其子块
+type ImportBlock struct {
+	Block    *lark.DocxBlock
+	Children []*ImportBlock
+	// ImageSource 图片地址（http/https 或 data URI），块创建后上传并回填 token
+	ImageSource string
+	// Cells 表格单元格内容，按行优先排列
+	Cells [][]*ImportBlock
+}
+
+// calloutPattern 匹配 GitHub 风格的提示块标记，例如 "> [!NOTE]"
+var calloutPattern = regexp.MustCompile(`^\[!(NOTE|TIP|IMPORTANT|WARNING|CAUTION)\]\s*`)
+
+// calloutStyles 提示块类型对应的背景色与图标
+var calloutStyles = map[string]lark.DocxBlockCallout{
+	"NOTE":      {BackgroundColor: lark.DocxCalloutBackgroundColorLightBlue, EmojiID: "memo"},
+	"TIP":       {BackgroundColor: lark.DocxCalloutBackgroundColorLightGreen, EmojiID: "bulb"},
+	"IMPORTANT": {BackgroundColor: lark.DocxCalloutBackgroundColorLightPurple, EmojiID: "pushpin"},
+	"WARNING":   {BackgroundColor: lark.DocxCalloutBackgroundColorLightYellow, EmojiID: "warning"},
+	"CAUTION":   {BackgroundColor: lark.DocxCalloutBackgroundColorLightRed, EmojiID: "no_entry"},
+}
+
+// mdStr2DocxCodeLang 代码语言到飞书代码块语言的映射，由导出时使用的映射反转得到
+var mdStr2DocxCodeLang = func() map[string]lark.DocxCodeLanguage {
+	langs := make(map[string]lark.DocxCodeLanguage, len(core.DocxCodeLang2MdStr))
+	for lang, name := range core.DocxCodeLang2MdStr {
+		if name != "" {
+			langs[name] = lang
+		}
+	}
+	// 常见别名
+	for alias, name := range map[string]string{
+		"sh": "shell", "zsh": "shell", "js": "javascript", "ts": "typescript",
+		"py": "python", "golang": "go", "yml": "yaml", "c++": "cpp", "cs": "csharp",
+		"objc": "objectivec", "rb": "ruby", "rs": "rust", "kt": "kotlin", "proto": "protobuf",
+		"md": "markdown", "ps1": "powershell", "tex": "latex",
+	} {
+		langs[alias] = langs[name]
+	}
+	return langs
+}()
+
+// ParseMarkdown 将 Markdown 解析为飞书文档块树，无法转换的内容记录在 warnings 中
+func ParseMarkdown(source []byte) ([]*ImportBlock, []string) {
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM))
+	doc := md.Parser().Parse(text.NewReader(source))
+
+	conv := &markdownConverter{source: source}
+	return conv.convertChildren(doc), conv.warnings
+}
+
+type markdownConverter struct {
+	source   []byte
+	warnings []string
+}
+
+func (m *markdownConverter) convertChildren(parent ast.Node) []*ImportBlock {
+	var blocks []*ImportBlock
+	for node := parent.FirstChild(); node != nil; node = node.NextSibling() {
+		blocks = append(blocks, m.convertBlock(node)...)
+	}
+	return blocks
+}
+
+func (m *markdownConverter) convertBlock(node ast.Node) []*ImportBlock {
+	switch n := node.(type) {
+	case *ast.Heading:
+		blockType := lark.DocxBlockTypeHeading1 + lark.DocxBlockType(n.Level-1)
+		return []*ImportBlock{{Block: newTextBlock(blockType, m.inlineElements(n, lark.DocxTextElementStyle{}), nil)}}
+	case *ast.Paragraph, *ast.TextBlock:
+		return m.convertParagraph(n)
+	case *ast.List:
+		return m.convertList(n)
+	case *ast.FencedCodeBlock:
+		return []*ImportBlock{m.convertCode(n, string(n.Language(m.source)))}
+	case *ast.CodeBlock:
+		return []*ImportBlock{m.convertCode(n, "")}
+	case *ast.Blockquote:
+		return []*ImportBlock{m.convertBlockquote(n)}
+	case *ast.ThematicBreak:
+		return []*ImportBlock{{Block: &lark.DocxBlock{BlockType: lark.DocxBlockTypeDivider}}}
+	case *east.Table:
+		return []*ImportBlock{m.convertTable(n)}
+	case *ast.HTMLBlock:
+		m.warnings = append(m.warnings, "HTML 块暂不支持，已跳过")
+		return nil
+	default:
+		m.warnings = append(m.warnings, fmt.Sprintf("不支持的 Markdown 元素 %s，已跳过", node.Kind()))
+		return nil
+	}
+}
+
+// convertParagraph 转换段落，段落中的图片拆分为独立的图片块
+func (m *markdownConverter) convertParagraph(node ast.Node) []*ImportBlock {
+	var blocks []*ImportBlock
+	var elements []*lark.DocxTextElement
+	flush := func() {
+		if len(elements) > 0 {
+			blocks = append(blocks, &ImportBlock{Block: newTextBlock(lark.DocxBlockTypeText, elements, nil)})
+			elements = nil
+		}
+	}
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		if img, ok := child.(*ast.Image); ok {
+			flush()
+			blocks = append(blocks, &ImportBlock{
+				Block:       &lark.DocxBlock{BlockType: lark.DocxBlockTypeImage, Image: &lark.DocxBlockImage{}},
+				ImageSource: string(img.Destination),
+			})
+			continue
+		}
+		elements = m.appendInline(elements, child, lark.DocxTextElementStyle{})
+	}
+	flush()
+	return blocks
+}
+
+// convertList 转换列表，列表项中除首段外的内容作为列表块的子块
+func (m *markdownConverter) convertList(list *ast.List) []*ImportBlock {
+	blockType := lark.DocxBlockTypeBullet
+	if list.IsOrdered() {
+		blockType = lark.DocxBlockTypeOrdered
+	}
+
+	var blocks []*ImportBlock
+	for item := list.FirstChild(); item != nil; item = item.NextSibling() {
+		itemType := blockType
+		var style *lark.DocxTextStyle
+		var elements []*lark.DocxTextElement
+		var children []*ImportBlock
+
+		first := item.FirstChild()
+		if first != nil && (first.Kind() == ast.KindParagraph || first.Kind() == ast.KindTextBlock) {
+			if box, ok := first.FirstChild().(*east.TaskCheckBox); ok {
+				itemType = lark.DocxBlockTypeTodo
+				style = &lark.DocxTextStyle{Done: box.IsChecked}
+			}
+			elements = m.inlineElements(first, lark.DocxTextElementStyle{})
+			first = first.NextSibling()
+		}
+		for node := first; node != nil; node = node.NextSibling() {
+			children = append(children, m.convertBlock(node)...)
+		}
+		blocks = append(blocks, &ImportBlock{Block: newTextBlock(itemType, elements, style), Children: children})
+	}
+	return blocks
+}
+
+func (m *markdownConverter) convertCode(node ast.Node, lang string) *ImportBlock {
+	buf := new(bytes.Buffer)
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		buf.Write(segment.Value(m.source))
+	}
+	code := strings.TrimSuffix(buf.String(), "\n")
+
+	language := lark.DocxCodeLanguagePlainText
+	if l, ok := mdStr2DocxCodeLang[strings.ToLower(lang)]; ok {
+		language = l
+	}
+	elements := []*lark.DocxTextElement{newTextRun(code, lark.DocxTextElementStyle{})}
+	return &ImportBlock{Block: newTextBlock(lark.DocxBlockTypeCode, elements, &lark.DocxTextStyle{Language: language})}
+}
+
+// convertBlockquote 转换引用，以 [!NOTE] 等标记开头的引用转换为高亮块
+func (m *markdownConverter) convertBlockquote(quote *ast.Blockquote) *ImportBlock {
+	children := m.convertChildren(quote)
+
+	if len(children) > 0 && children[0].Block.Text != nil {
+		elements := children[0].Block.Text.Elements
+		if len(elements) > 0 && elements[0].TextRun != nil {
+			if match := calloutPattern.FindStringSubmatch(elements[0].TextRun.Content); match != nil {
+				elements[0].TextRun.Content = strings.TrimPrefix(elements[0].TextRun.Content, match[0])
+				if elements[0].TextRun.Content == "" {
+					elements = elements[1:]
+				}
+				children[0].Block.Text.Elements = elements
+				if len(elements) == 0 {
+					children = children[1:]
+				}
+				callout := calloutStyles[match[1]]
+				return &ImportBlock{
+					Block:    &lark.DocxBlock{BlockType: lark.DocxBlockTypeCallout, Callout: &callout},
+					Children: children,
+				}
+			}
+		}
+	}
+
+	return &ImportBlock{
+		Block:    &lark.DocxBlock{BlockType: lark.DocxBlockTypeQuoteContainer, QuoteContainer: &lark.DocxBlocQuoteContainer{}},
+		Children: children,
+	}
+}
+
+// convertTable 转换表格，表头单元格加粗显示
+func (m *markdownConverter) convertTable(table *east.Table) *ImportBlock {
+	var cells [][]*ImportBlock
+	var rows, columns int64
+	for row := table.FirstChild(); row != nil; row = row.NextSibling() {
+		style := lark.DocxTextElementStyle{}
+		if row.Kind() == east.KindTableHeader {
+			style.Bold = true
+		}
+		var count int64
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			var content []*ImportBlock
+			if elements := m.inlineElements(cell, style); len(elements) > 0 {
+				content = append(content, &ImportBlock{Block: newTextBlock(lark.DocxBlockTypeText, elements, nil)})
+			}
+			cells = append(cells, content)
+			count++
+		}
+		// 列数不足的行补齐空单元格
+		for ; count < int64(len(table.Alignments)); count++ {
+			cells = append(cells, nil)
+		}
+		rows++
+	}
+	columns = int64(len(table.Alignments))
+
+	return &ImportBlock{
+		Block: &lark.DocxBlock{
+			BlockType: lark.DocxBlockTypeTable,
+			Table: &lark.DocxBlockTable{
+				Property: &lark.DocxBlockTableProperty{RowSize: rows, ColumnSize: columns},
+			},
+		},
+		Cells: cells,
+	}
+}
+
+// inlineElements 转换节点下的所有行内元素
+func (m *markdownConverter) inlineElements(node ast.Node, style lark.DocxTextElementStyle) []*lark.DocxTextElement {
+	var elements []*lark.DocxTextElement
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		elements = m.appendInline(elements, child, style)
+	}
+	return elements
+}
+
+func (m *markdownConverter) appendInline(elements []*lark.DocxTextElement, node ast.Node, style lark.DocxTextElementStyle) []*lark.DocxTextElement {
+	switch n := node.(type) {
+	case *ast.Text:
+		content := string(n.Segment.Value(m.source))
+		if n.HardLineBreak() {
+			content += "\n"
+		} else if n.SoftLineBreak() {
+			content += " "
+		}
+		return appendTextRun(elements, content, style)
+	case *ast.String:
+		return appendTextRun(elements, string(n.Value), style)
+	case *ast.CodeSpan:
+		style.InlineCode = true
+		buf := new(strings.Builder)
+		for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+			if t, ok := child.(*ast.Text); ok {
+				buf.Write(t.Segment.Value(m.source))
+			}
+		}
+		return appendTextRun(elements, buf.String(), style)
+	case *ast.Emphasis:
+		if n.Level >= 2 {
+			style.Bold = true
+		} else {
+			style.Italic = true
+		}
+	case *east.Strikethrough:
+		style.Strikethrough = true
+	case *ast.Link:
+		style.Link = &lark.DocxTextElementStyleLink{URL: url.QueryEscape(string(n.Destination))}
+	case *ast.AutoLink:
+		link := string(n.URL(m.source))
+		style.Link = &lark.DocxTextElementStyleLink{URL: url.QueryEscape(link)}
+		return appendTextRun(elements, string(n.Label(m.source)), style)
+	case *ast.Image:
+		// 行内图片（如链接中的图片）无法单独成块，保留替代文本
+	case *east.TaskCheckBox:
+		return elements
+	case *ast.RawHTML:
+		return elements
+	}
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		elements = m.appendInline(elements, child, style)
+	}
+	return elements
+}
+
+// appendTextRun 追加文本，与前一段样式相同时合并
+func appendTextRun(elements []*lark.DocxTextElement, content string, style lark.DocxTextElementStyle) []*lark.DocxTextElement {
+	if content == "" {
+		return elements
+	}
+	if n := len(elements); n > 0 && elements[n-1].TextRun != nil && sameStyle(elements[n-1].TextRun.TextElementStyle, style) {
+		elements[n-1].TextRun.Content += content
+		return elements
+	}
+	return append(elements, newTextRun(content, style))
+}
+
+func newTextRun(content string, style lark.DocxTextElementStyle) *lark.DocxTextElement {
+	run := &lark.DocxTextElementTextRun{Content: content}
+	if style != (lark.DocxTextElementStyle{}) {
+		run.TextElementStyle = &style
+	}
+	return &lark.DocxTextElement{TextRun: run}
+}
+
+func sameStyle(current *lark.DocxTextElementStyle, style lark.DocxTextElementStyle) bool {
+	if current == nil {
+		return style == (lark.DocxTextElementStyle{})
+	}
+	a, b := *current, style
+	if (a.Link == nil) != (b.Link == nil) || (a.Link != nil && a.Link.URL != b.Link.URL) {
+		return false
+	}
+	a.Link, b.Link = nil, nil
+	return a == b
+}
+
+// newTextBlock 创建文本类的块，按块类型设置对应字段
+func newTextBlock(blockType lark.DocxBlockType, elements []*lark.DocxTextElement, style *lark.DocxTextStyle) *lark.DocxBlock {
+	if style == nil {
+		style = &lark.DocxTextStyle{Align: 1}
+	}
+	if len(elements) == 0 {
+		// 飞书不允许创建没有文本元素的块
+		elements = []*lark.DocxTextElement{{TextRun: &lark.DocxTextElementTextRun{}}}
+	}
+	text := &lark.DocxBlockText{Style: style, Elements: elements}
+
+	block := &lark.DocxBlock{BlockType: blockType}
+	switch blockType {
+	case lark.DocxBlockTypeHeading1:
+		block.Heading1 = text
+	case lark.DocxBlockTypeHeading2:
+		block.Heading2 = text
+	case lark.DocxBlockTypeHeading3:
+		block.Heading3 = text
+	case lark.DocxBlockTypeHeading4:
+		block.Heading4 = text
+	case lark.DocxBlockTypeHeading5:
+		block.Heading5 = text
+	case lark.DocxBlockTypeHeading6:
+		block.Heading6 = text
+	case lark.DocxBlockTypeHeading7:
+		block.Heading7 = text
+	case lark.DocxBlockTypeHeading8:
+		block.Heading8 = text
+	case lark.DocxBlockTypeHeading9:
+		block.Heading9 = text
+	case lark.DocxBlockTypeBullet:
+		block.Bullet = text
+	case lark.DocxBlockTypeOrdered:
+		block.Ordered = text
+	case lark.DocxBlockTypeCode:
+		block.Code = text
+	case lark.DocxBlockTypeTodo:
+		block.Todo = text
+	default:
+		block.BlockType = lark.DocxBlockTypeText
+		block.Text = text
+	}
+	return block
+}
+
+// plainText 拼接块中的纯文本
+func plainText(block *lark.DocxBlock) string {
+	buf := new(strings.Builder)
+	for _, text := range []*lark.DocxBlockText{block.Text, block.Heading1} {
+		if text == nil {
+			continue
+		}
+		for _, element := range text.Elements {
+			if element.TextRun != nil {
+				buf.WriteString(element.TextRun.Content)
+			}
+		}
+	}
+	return buf.String()
+}
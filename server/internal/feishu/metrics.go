@@ -0,0 +1,26 @@
+package feishu
+
+import (
+	"feishu2md/server/pkg/metrics"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// metricsTransport 按租户记录开放平台请求的次数、状态码与耗时
+type metricsTransport struct {
+	tenant string
+	next   http.RoundTripper
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	metrics.FeishuAPIDuration.WithLabelValues(t.tenant).Observe(time.Since(start).Seconds())
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	metrics.FeishuAPIRequests.WithLabelValues(t.tenant, status).Inc()
+	return resp, err
+}
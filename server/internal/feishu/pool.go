@@ -0,0 +1,61 @@
+package feishu
+
+import (
+	"feishu2md/server/internal/ratelimit"
+	"feishu2md/server/pkg/conf"
+	"github.com/chyroc/lark"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Pool 按域名与应用复用飞书客户端，所有客户端共享同一个连接池、tenant_access_token 缓存与重试策略
+type Pool struct {
+	store     lark.Store
+	transport http.RoundTripper
+	timeout   time.Duration
+	retry     conf.FeishuRetryConfig
+	limiter   ratelimit.Limiter
+	rateLimit conf.RateLimitConfig
+
+	mu      sync.Mutex
+	clients map[string]*Client
+}
+
+// NewPool 按配置创建连接池，shared 为 nil 时令牌只缓存在进程内，limiter 为 nil 时不限流
+func NewPool(cfg conf.FeishuConfig, shared SharedStore, limiter ratelimit.Limiter) *Pool {
+	dialer := &net.Dialer{Timeout: cfg.HTTP.DialTimeout, KeepAlive: 30 * time.Second}
+	return &Pool{
+		store: NewTokenStore(shared, cfg.TokenRefreshBefore),
+		transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   cfg.HTTP.MaxIdleConnsPerHost,
+			IdleConnTimeout:       cfg.HTTP.IdleConnTimeout,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+		timeout:   cfg.HTTP.Timeout,
+		retry:     cfg.Retry,
+		limiter:   limiter,
+		rateLimit: cfg.RateLimit,
+		clients:   make(map[string]*Client),
+	}
+}
+
+// Client 返回域名与应用对应的客户端，首次调用时按 opts 创建
+func (p *Pool) Client(appID, appSecret, domain string, opts ...ClientOption) *Client {
+	key := domain + "/" + appID
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if client, ok := p.clients[key]; ok {
+		return client
+	}
+	opts = append([]ClientOption{WithStore(p.store), WithHTTP(p.transport, p.timeout), WithRetry(p.retry), WithRateLimit(p.limiter, p.rateLimit)}, opts...)
+	client := NewClient(appID, appSecret, domain, opts...)
+	p.clients[key] = client
+	return client
+}
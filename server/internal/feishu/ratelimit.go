@@ -0,0 +1,55 @@
+package feishu
+
+import (
+	"context"
+	"feishu2md/server/internal/ratelimit"
+	"feishu2md/server/pkg/conf"
+	"github.com/chyroc/lark"
+	"strings"
+)
+
+// apiFamily 请求所属的 API 类别，飞书按类别分别限流；返回空字符串的请求不限流
+func apiFamily(req *lark.RawRequestReq) string {
+	switch {
+	case req.Scope == "Bitable":
+		return "bitable"
+	case strings.Contains(req.API, "Sheet"):
+		return "sheets"
+	case strings.Contains(req.API, "Media"), strings.Contains(req.API, "DriveFile"):
+		return "media"
+	case strings.Contains(req.API, "Docx"):
+		return "blocks"
+	}
+	return ""
+}
+
+// budgets 一个租户各 API 类别的额度，租户的配置覆盖默认额度
+func budgets(cfg conf.RateLimitConfig, tenant string) map[string]ratelimit.Limit {
+	limits := make(map[string]ratelimit.Limit)
+	for family, budget := range cfg.Budgets {
+		limits[family] = ratelimit.Limit{Rate: budget.Rate, Burst: budget.Burst}
+	}
+	for family, budget := range cfg.Tenants[strings.ToLower(tenant)] {
+		limits[family] = ratelimit.Limit{Rate: budget.Rate, Burst: budget.Burst}
+	}
+	return limits
+}
+
+// throttle 按应用与 API 类别限流的 SDK 中间件，位于重试之内，每次重试同样消耗额度
+type throttle struct {
+	limiter ratelimit.Limiter
+	appID   string
+	limits  map[string]ratelimit.Limit
+}
+
+func (t *throttle) middleware(next lark.ApiEndpoint) lark.ApiEndpoint {
+	return func(ctx context.Context, req *lark.RawRequestReq, resp interface{}) (*lark.Response, error) {
+		if family := apiFamily(req); family != "" {
+			// 飞书的频率限制按应用计算，同一应用的不同域名共享额度
+			if err := ratelimit.Wait(ctx, t.limiter, t.appID+":"+family, t.limits[family]); err != nil {
+				return nil, err
+			}
+		}
+		return next(ctx, req, resp)
+	}
+}
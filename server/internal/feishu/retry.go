@@ -0,0 +1,111 @@
+package feishu
+
+import (
+	"context"
+	"errors"
+	"feishu2md/server/pkg/conf"
+	"feishu2md/server/pkg/metrics"
+	"github.com/chyroc/lark"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRetryPolicy 未指定重试策略时使用
+var DefaultRetryPolicy = conf.FeishuRetryConfig{
+	MaxAttempts:     4,
+	InitialInterval: 500 * time.Millisecond,
+	MaxInterval:     10 * time.Second,
+	MaxElapsedTime:  30 * time.Second,
+}
+
+// retryer 按重试策略重新发送开放平台请求的 SDK 中间件，需位于 classifyErrors 之外以便按错误分类判断
+type retryer struct {
+	policy conf.FeishuRetryConfig
+	tenant string
+}
+
+func (r *retryer) middleware(next lark.ApiEndpoint) lark.ApiEndpoint {
+	return func(ctx context.Context, req *lark.RawRequestReq, resp interface{}) (*lark.Response, error) {
+		// 上传文件的请求体是只能读取一次的 io.Reader，不能重新发送
+		if r.policy.MaxAttempts <= 1 || req.IsFile {
+			addAttempt(ctx, req)
+			return next(ctx, req, resp)
+		}
+		start := time.Now()
+		for attempt := 1; ; attempt++ {
+			addAttempt(ctx, req)
+			response, err := next(ctx, req, resp)
+			reason := retryReason(req, err)
+			if reason == "" || attempt >= r.policy.MaxAttempts {
+				return response, err
+			}
+			wait, ok := retryAfter(response)
+			if !ok {
+				wait = r.backoff(attempt)
+			}
+			if r.policy.MaxElapsedTime > 0 && time.Since(start)+wait > r.policy.MaxElapsedTime {
+				return response, err
+			}
+			metrics.FeishuAPIRetries.WithLabelValues(r.tenant, reason).Inc()
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return response, errors.Join(ctx.Err(), err)
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+type attemptsKey struct{}
+
+// CountAttempts 返回统计请求次数的上下文，使用该上下文的开放平台请求每次发送（包括重试）都计入，
+// 获取访问令牌的请求不计入，count 返回当前次数
+func CountAttempts(ctx context.Context) (_ context.Context, count func() int) {
+	n := new(atomic.Int64)
+	return context.WithValue(ctx, attemptsKey{}, n), func() int { return int(n.Load()) }
+}
+
+// addAttempt 上下文由 CountAttempts 创建时次数加一
+func addAttempt(ctx context.Context, req *lark.RawRequestReq) {
+	if n, ok := ctx.Value(attemptsKey{}).(*atomic.Int64); ok && req.Scope != "Auth" {
+		n.Add(1)
+	}
+}
+
+// retryReason 返回重试原因，不应重试时为空：限流总是重试，服务端错误只重试不会产生副作用的 GET 请求
+func retryReason(req *lark.RawRequestReq, err error) string {
+	switch {
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrServer) && req.Method == http.MethodGet:
+		return "server_error"
+	}
+	return ""
+}
+
+// backoff 第 attempt 次失败后的等待时间，在指数增长的上限与其一半之间随机取值，避免多个请求同时重试
+func (r *retryer) backoff(attempt int) time.Duration {
+	wait := r.policy.InitialInterval << (attempt - 1)
+	if wait > r.policy.MaxInterval || wait <= 0 {
+		wait = r.policy.MaxInterval
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// retryAfter 读取响应中服务端要求的等待时间：Retry-After 或飞书限流的 x-ogw-ratelimit-reset，单位均为秒
+func retryAfter(response *lark.Response) (time.Duration, bool) {
+	if response == nil || response.Header == nil {
+		return 0, false
+	}
+	for _, name := range []string{"Retry-After", "X-Ogw-Ratelimit-Reset"} {
+		if seconds, err := strconv.Atoi(response.Header.Get(name)); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,74 @@
+package feishu
+
+import (
+	"context"
+	"github.com/chyroc/lark"
+	"sync"
+	"time"
+)
+
+// SharedStore 多实例共享的令牌缓存，由 cache.Failover 实现
+type SharedStore interface {
+	GetEX(ctx context.Context, key string) (value string, ttl time.Duration, ok bool, err error)
+	SetEX(ctx context.Context, key, value string, ttl time.Duration) error
+}
+
+// sharedKeyPrefix 令牌在共享缓存中的 key 前缀
+const sharedKeyPrefix = "feishu:token:"
+
+type storeEntry struct {
+	value   string
+	expires time.Time
+}
+
+// tokenStore 实现 lark.Store，缓存 SDK 获取的 tenant_access_token：先查进程内缓存，再查共享缓存。
+// 剩余有效期不足 refreshBefore 的令牌视为不存在，SDK 会在过期前重新获取
+type tokenStore struct {
+	shared        SharedStore
+	refreshBefore time.Duration
+
+	mu    sync.Mutex
+	local map[string]storeEntry
+}
+
+// NewTokenStore 创建令牌缓存，shared 为 nil 时只缓存在进程内
+func NewTokenStore(shared SharedStore, refreshBefore time.Duration) lark.Store {
+	return &tokenStore{shared: shared, refreshBefore: refreshBefore, local: make(map[string]storeEntry)}
+}
+
+func (s *tokenStore) Get(ctx context.Context, key string) (string, time.Duration, error) {
+	s.mu.Lock()
+	entry, ok := s.local[key]
+	s.mu.Unlock()
+	if ok {
+		if ttl := time.Until(entry.expires); ttl > s.refreshBefore {
+			return entry.value, ttl, nil
+		}
+	}
+	if s.shared == nil {
+		return "", 0, lark.ErrStoreNotFound
+	}
+	value, ttl, ok, err := s.shared.GetEX(ctx, sharedKeyPrefix+key)
+	if err != nil {
+		return "", 0, err
+	}
+	if !ok || ttl <= s.refreshBefore {
+		return "", 0, lark.ErrStoreNotFound
+	}
+	s.setLocal(key, value, ttl)
+	return value, ttl, nil
+}
+
+func (s *tokenStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	s.setLocal(key, value, ttl)
+	if s.shared == nil {
+		return nil
+	}
+	return s.shared.SetEX(ctx, sharedKeyPrefix+key, value, ttl)
+}
+
+func (s *tokenStore) setLocal(key, value string, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.local[key] = storeEntry{value: value, expires: time.Now().Add(ttl)}
+}
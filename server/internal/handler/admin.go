@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"feishu2md/server/internal/config"
+	"feishu2md/server/internal/logger"
+	"feishu2md/server/internal/model"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// adminToken 当前配置的管理接口令牌
+func (h *Handler) adminToken() string {
+	if admin := h.app.Config.Current().Admin; admin != nil {
+		return admin.Token
+	}
+	return ""
+}
+
+// reloadConfig 重新加载配置文件，校验失败时保留原配置
+func (h *Handler) reloadConfig(c *gin.Context) {
+	log := logger.WithRequest(c.Request)
+	old := h.app.Config.Current()
+	cfg, err := h.app.Config.Reload()
+	if err != nil {
+		log.Error("Config reload rejected", zap.Error(err))
+		model.Error(c, 2003, "配置校验失败: "+err.Error())
+		return
+	}
+	log.Info("Config reloaded")
+	model.Success(c, gin.H{
+		"env":              cfg.Env,
+		"restart_required": config.RestartRequired(old, cfg),
+	})
+}
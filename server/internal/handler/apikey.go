@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"feishu2md/server/internal/logger"
+	"feishu2md/server/internal/middlewares"
+	"feishu2md/server/internal/model"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"strconv"
+)
+
+// createAPIKey 为当前用户创建 API Key，明文只在本次响应中返回
+func (h *Handler) createAPIKey(c *gin.Context) {
+	var req model.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		model.ParamError(c, err)
+		return
+	}
+	isAdmin := middlewares.ValidAdminToken(c, h.adminToken())
+	key, err := h.app.APIKeys.Create(c.GetInt("userID"), req, isAdmin)
+	if err != nil {
+		if _, ok := err.(*model.ErrorResponse); !ok {
+			logger.WithRequest(c.Request).Error("Failed to create api key", zap.Error(err))
+		}
+		respondError(c, err)
+		return
+	}
+	logger.WithRequest(c.Request).Info("API key created",
+		zap.Int("id", key.ID), zap.Int("user_id", key.UserID), zap.Strings("scopes", key.Scopes))
+	model.Success(c, key)
+}
+
+// listAPIKeys 列出当前用户的 API Key，不包含明文
+func (h *Handler) listAPIKeys(c *gin.Context) {
+	keys, err := h.app.APIKeys.List(c.GetInt("userID"))
+	if err != nil {
+		logger.WithRequest(c.Request).Error("Failed to list api keys", zap.Error(err))
+		respondError(c, err)
+		return
+	}
+	model.Success(c, keys)
+}
+
+// revokeAPIKey 吊销当前用户的一个 API Key
+func (h *Handler) revokeAPIKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		model.ParamError(c, err)
+		return
+	}
+	if err := h.app.APIKeys.Revoke(c.GetInt("userID"), id); err != nil {
+		if _, ok := err.(*model.ErrorResponse); !ok {
+			logger.WithRequest(c.Request).Error("Failed to revoke api key", zap.Error(err))
+		}
+		respondError(c, err)
+		return
+	}
+	logger.WithRequest(c.Request).Info("API key revoked", zap.Int("id", id), zap.Int("user_id", c.GetInt("userID")))
+	model.Success(c, gin.H{"id": id})
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"feishu2md/server/internal/feishu"
+	"feishu2md/server/internal/logger"
+	"feishu2md/server/internal/model"
+	"feishu2md/server/internal/service/account"
+	"feishu2md/server/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"sync"
+)
+
+// loginFeishu 用飞书授权码登录，首次登录时创建用户，返回与手机号登录相同的令牌
+func (h *Handler) loginFeishu(c *gin.Context) {
+	var req model.TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		model.ParamError(c, err)
+		return
+	}
+	log := logger.WithRequest(c.Request)
+	user, feishuAccount, err := h.app.Accounts.SignIn(c.Request.Context(), &req)
+	if err != nil {
+		log.Error("Feishu sign in failed", zap.Error(err))
+		if errors.Is(err, tenant.ErrUnknownDomain) {
+			respondError(c, errUnknownDomain)
+			return
+		}
+		if errors.Is(err, account.ErrAuthorize) {
+			model.Error(c, 1002, "飞书授权失败")
+			return
+		}
+		respondError(c, err)
+		return
+	}
+	pair, err := h.app.Tokens.Issue(c.Request.Context(), user.ID)
+	if err != nil {
+		log.Error("Failed to issue token", zap.Error(err))
+		model.Error(c, 1001, "生成token失败")
+		return
+	}
+	log.Info("Feishu sign in", zap.Int("user_id", user.ID), zap.String("domain", feishuAccount.Domain), zap.String("open_id", feishuAccount.OpenID))
+
+	model.Success(c, gin.H{
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"token_type":    pair.TokenType,
+		"expires_in":    pair.ExpiresIn,
+		"user":          user,
+		"feishu":        feishuAccount,
+	})
+}
+
+// linkFeishu 将飞书账号关联到当前用户，此后转换等接口无需再传 user_access_token
+func (h *Handler) linkFeishu(c *gin.Context) {
+	var req model.TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		model.ParamError(c, err)
+		return
+	}
+	feishuAccount, err := h.app.Accounts.Link(c.Request.Context(), c.GetInt("userID"), &req)
+	if err != nil {
+		if _, ok := err.(*model.ErrorResponse); !ok {
+			logger.WithRequest(c.Request).Error("Failed to link feishu account", zap.Error(err))
+		}
+		if errors.Is(err, tenant.ErrUnknownDomain) {
+			respondError(c, errUnknownDomain)
+			return
+		}
+		if errors.Is(err, account.ErrAuthorize) {
+			model.Error(c, 1002, "飞书授权失败")
+			return
+		}
+		respondError(c, err)
+		return
+	}
+	model.Success(c, feishuAccount)
+}
+
+// getFeishuAccount 返回当前用户关联的飞书账号，指定 domain 时只返回该租户下的账号
+func (h *Handler) getFeishuAccount(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetInt("userID")
+	if domain := c.Query("domain"); domain != "" {
+		feishuAccount, err := h.app.Accounts.Account(ctx, userID, domain)
+		if errors.Is(err, account.ErrNotLinked) {
+			model.Error(c, http.StatusNotFound, "未关联飞书账号")
+			return
+		}
+		if err != nil {
+			logger.WithRequest(c.Request).Error("Failed to get feishu account", zap.Error(err))
+			respondError(c, err)
+			return
+		}
+		model.Success(c, feishuAccount)
+		return
+	}
+	accounts, err := h.app.Accounts.Accounts(ctx, userID)
+	if err != nil {
+		logger.WithRequest(c.Request).Error("Failed to list feishu accounts", zap.Error(err))
+		respondError(c, err)
+		return
+	}
+	if len(accounts) == 0 {
+		model.Error(c, http.StatusNotFound, "未关联飞书账号")
+		return
+	}
+	model.Success(c, accounts)
+}
+
+// listTenants 返回可登录的飞书租户及其网页授权地址，前端据此发起授权
+func (h *Handler) listTenants(c *gin.Context) {
+	model.Success(c, h.app.Tenants.Tenants())
+}
+
+// documentDomain 返回文档链接所属租户的域名，无法识别时为默认租户
+func (h *Handler) documentDomain(url string) string {
+	if matches := h.domainPattern.FindStringSubmatch(url); matches != nil {
+		return matches[1]
+	}
+	return tenant.DefaultDomain
+}
+
+// errUnknownDomain 文档或授权的域名不属于任何飞书租户
+var errUnknownDomain = &model.ErrorResponse{Code: http.StatusBadRequest, Message: "不支持的飞书域名"}
+
+// domainError 将 tenant.ErrUnknownDomain 转为 400 响应，其余错误原样返回
+func domainError(err error) error {
+	if errors.Is(err, tenant.ErrUnknownDomain) {
+		return errUnknownDomain
+	}
+	return err
+}
+
+// feishuClient 返回域名所属租户的飞书客户端，域名不属于任何租户时返回 errUnknownDomain
+func (h *Handler) feishuClient(domain string) (*feishu.Client, error) {
+	client, err := h.app.FeishuClient(domain)
+	return client, domainError(err)
+}
+
+// UserToken 返回发起请求的用户的 user_access_token，为空时以应用身份访问文档
+type UserToken func(ctx context.Context) (string, error)
+
+// errReauthorize 保存的刷新令牌已失效，需要用户重新授权
+var errReauthorize = &model.ErrorResponse{Code: http.StatusUnauthorized, Message: "飞书授权已过期，请重新关联飞书账号"}
+
+// userToken 返回按当前用户从令牌库取得租户 domain 下 user_access_token 的函数，令牌即将过期时自动刷新，结果在请求内复用。
+// 令牌库优先；仅当用户未关联该租户的飞书账号时使用请求携带的令牌 given（兼容旧客户端），二者皆无时返回空字符串
+func (h *Handler) userToken(c *gin.Context, domain, given string) UserToken {
+	userID := c.GetInt("userID")
+	var (
+		once  sync.Once
+		token string
+		err   error
+	)
+	return func(ctx context.Context) (string, error) {
+		once.Do(func() {
+			token, err = h.app.Accounts.UserAccessToken(ctx, userID, domain)
+			switch {
+			case errors.Is(err, account.ErrNotLinked):
+				token, err = given, nil
+			case errors.Is(err, account.ErrReauthorize):
+				logger.WithRequest(c.Request).Warn("Stored feishu token expired", zap.Int("user_id", userID), zap.String("domain", domain), zap.Error(err))
+				err = errReauthorize
+			}
+		})
+		return token, err
+	}
+}
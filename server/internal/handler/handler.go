@@ -3,25 +3,20 @@ package handler
 import (
 	"context"
 	"encoding/json"
-	"feishu2md/server/internal/config"
+	"errors"
+	"feishu2md/server/internal/container"
 	"feishu2md/server/internal/feishu"
 	"feishu2md/server/internal/logger"
+	"feishu2md/server/internal/middlewares"
 	"feishu2md/server/internal/model"
-	"feishu2md/server/internal/repository/cache"
-	"feishu2md/server/internal/repository/database"
-	"feishu2md/server/internal/repository/storage"
-	"feishu2md/server/internal/service/auth"
-	"feishu2md/server/internal/service/captcha"
+	"feishu2md/server/internal/progress"
 	"feishu2md/server/internal/service/img"
-	services "feishu2md/server/internal/service/transform"
-	user2 "feishu2md/server/internal/service/user"
+	"feishu2md/server/pkg/conf"
 	"feishu2md/server/pkg/metrics"
-	"feishu2md/server/pkg/utils"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -31,31 +26,78 @@ import (
 	"time"
 )
 
-func RegisterRoutes(router *gin.Engine, storage *storage.LocalStorage) {
-	router.GET("/health", healthCheck)
-	router.POST("/v1/upload", uploadFile)                  // 新增上传接口
-	router.POST("/v1/transform", transformV1)              // 文件解析接口
-	router.POST("/v1/feishu/access_token", getAccessToken) //获取accessToken接口
-	router.GET("/api/captcha/get", getCaptcha)
-	router.POST("/api/captcha/refresh", refreshCaptcha)
-	router.POST("/api/register", register)
-	router.POST("/api/login", login)
-	router.GET("/v1/getHistory", getHistory)
-	router.GET("/storage/*filename", func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")                                    // 允许所有来源
-		c.Header("Access-Control-Allow-Methods", "GET, OPTIONS")                        // 允许的方法
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization") // 允许的请求头
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-		http.ServeFile(c.Writer, c.Request, filepath.Join(storage.BaseDir, c.Param("filename")))
-	})
+// Handler HTTP 接口处理器，依赖由启动时创建的 Container 注入
+type Handler struct {
+	app *container.Container
+	// documentPattern、filePattern 与 domainPattern 按租户配置匹配文档链接，域名为第一个分组
+	documentPattern *regexp.Regexp
+	filePattern     *regexp.Regexp
+	domainPattern   *regexp.Regexp
 }
 
-func getHistory(c *gin.Context) {
-	userIDVal, exists := c.Get("userID")
-	if !exists {
+// NewHandler 创建 Handler
+func NewHandler(app *container.Container) *Handler {
+	host := `^https://[a-zA-Z0-9-]+\.` + app.Tenants.DomainPattern() + `/`
+	return &Handler{
+		app:             app,
+		documentPattern: regexp.MustCompile(host + `(doc|docs|docx|wiki|sheets|base|sheet|bitable)/([a-zA-Z0-9]+)`),
+		filePattern:     regexp.MustCompile(host + `(doc|docs|docx|wiki|file)/([a-zA-Z0-9]+)`),
+		domainPattern:   regexp.MustCompile(host),
+	}
+}
+
+func RegisterRoutes(router *gin.Engine, h *Handler) {
+	// 转换与历史记录接口同时接受 JWT 与具有对应权限的 API Key，其余用户接口只接受 JWT；
+	// 用户身份一律取自令牌
+	transformAuth := middlewares.AuthMiddleware(h.app.Tokens, h.app.APIKeys, model.ScopeTransform)
+	historyAuth := middlewares.AuthMiddleware(h.app.Tokens, h.app.APIKeys, model.ScopeHistory)
+	jwtAuth := middlewares.JWTMiddleware(h.app.Tokens)
+
+	// 公开接口
+	router.GET("/health", h.healthCheck)
+	router.GET("/storage/*filename", h.serveStorage) // 本地存储的文件，需要链接中的签名
+	public := router.Group("/api")
+	public.GET("/captcha/get", h.getCaptcha)
+	public.POST("/captcha/refresh", h.refreshCaptcha)
+	public.POST("/register", h.register)
+	public.POST("/login", h.login)
+	public.POST("/login/feishu", h.loginFeishu)   // 飞书授权码登录
+	public.GET("/feishu/tenants", h.listTenants)  // 可登录的飞书租户
+	public.POST("/token/refresh", h.refreshToken) // 用刷新令牌换取新的令牌
+
+	// 转换接口
+	transform := router.Group("/v1", transformAuth)
+	transform.POST("/transform", h.transformV1)            // 文件解析接口
+	transform.POST("/transform/stream", h.transformStream) // 以 Server-Sent Events 推送进度的文件解析接口
+	transform.POST("/upload", h.uploadFile)                // 新增上传接口
+
+	// 历史记录接口，只能访问自己的记录
+	history := router.Group("/v1", historyAuth)
+	history.GET("/getHistory", h.getHistory)
+	history.GET("/history", h.listHistory)              // 历史记录分页查询
+	history.GET("/history/:id", h.getHistoryItem)       // 历史记录详情
+	history.DELETE("/history/:id", h.deleteHistoryItem) // 删除历史记录
+	history.GET("/search", h.searchHistory)             // 历史记录全文搜索
+
+	// 只接受 JWT 的用户接口，API Key 只能由登录用户管理，不能用 API Key 创建新的 Key
+	user := router.Group("", jwtAuth)
+	user.POST("/api/logout", h.logout)                 // 注销当前令牌
+	user.POST("/v1/feishu/link", h.linkFeishu)         // 关联飞书账号
+	user.GET("/v1/feishu/account", h.getFeishuAccount) // 已关联的飞书账号
+	user.POST("/v1/diff", h.diffRevisions)             // 文档版本对比
+	user.POST("/v1/diff/history", h.diffHistory)       // 历史记录对比
+	user.POST("/v1/import", h.importMarkdown)          // Markdown 导入飞书文档
+	user.POST("/v1/api-keys", h.createAPIKey)          // 创建 API Key
+	user.GET("/v1/api-keys", h.listAPIKeys)            // API Key 列表
+	user.DELETE("/v1/api-keys/:id", h.revokeAPIKey)    // 吊销 API Key
+
+	admin := router.Group("/admin", middlewares.AdminMiddleware(h.adminToken, h.app.APIKeys))
+	admin.POST("/config/reload", h.reloadConfig) // 重新加载配置
+}
+
+func (h *Handler) getHistory(c *gin.Context) {
+	userID := c.GetInt("userID")
+	if userID == 0 {
 		c.JSON(http.StatusUnauthorized, model.Resp{
 			Code:    http.StatusUnauthorized,
 			Msg:     "Unauthorized",
@@ -63,18 +105,9 @@ func getHistory(c *gin.Context) {
 		})
 		return
 	}
-	userID := userIDVal.(int) // 根据你的 claims 结构类型转换
-
-	db, err := database.InitializeDB(DSN) // 更新为你的数据库连接信息
-	if err != nil {
-		logger.L.Fatal("Failed to initialize database")
-		return
-	}
-	// 创建 TransformService 实例
-	service := services.NewTransformService(db) // db 是你已连接的数据库实例
 
 	// 获取历史解析数据
-	transforms, err := service.GetHistory(userID)
+	transforms, err := h.app.Transforms.GetHistory(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.Resp{
 			Code:    http.StatusInternalServerError,
@@ -94,60 +127,47 @@ func getHistory(c *gin.Context) {
 	})
 }
 
-const DSN = "root:SCM15172008724mysql@tcp(127.0.0.1:3306)/feishu?charset=utf8mb4&parseTime=True&loc=Local"
-
-func login(c *gin.Context) {
+func (h *Handler) login(c *gin.Context) {
 	var req model.LoginRequest
 	// 绑定请求数据
 	if err := c.ShouldBindJSON(&req); err != nil {
 		model.Error(c, 1003, "请求参数错误")
 		return
 	}
-	db, err := database.InitializeDB(DSN) // 更新为你的数据库连接信息
-	if err != nil {
-		log.Fatal("Failed to initialize database: ", err)
-		return
-	}
-	userService := user2.NewUserService(db)
-	user, err := userService.LoginUser(req.Phone, req.Password)
+	user, err := h.app.Users.LoginUser(req.Phone, req.Password)
 	if err != nil {
 		model.Error(c, 1002, "登录失败")
 		return
 	}
-	// 生成JWT Token
-	token, err := utils.GenerateJWTToken(user.ID)
+	// 生成访问令牌与刷新令牌
+	pair, err := h.app.Tokens.Issue(c.Request.Context(), user.ID)
 	if err != nil {
+		logger.WithRequest(c.Request).Error("Failed to issue token", zap.Error(err))
 		model.Error(c, 1001, "生成token失败")
 		return
 	}
 
 	model.Success(c, gin.H{
-		"token": token,
-		"user":  user,
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"token_type":    pair.TokenType,
+		"expires_in":    pair.ExpiresIn,
+		"user":          user,
 	})
 }
-func register(c *gin.Context) {
+func (h *Handler) register(c *gin.Context) {
 	var req model.RegisterRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "参数错误", "content": err.Error()})
 		return
 	}
-	service := captcha.NewCaptService()
 	// 验证图形验证码
-	if !service.VerifyCaptcha(req.CaptchaID, req.CaptchaCode) {
+	if !h.app.Captcha.VerifyCaptcha(req.CaptchaID, req.CaptchaCode) {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "图形验证码错误"})
 		return
 	}
-	db, err := database.InitializeDB(DSN) // 更新为你的数据库连接信息
-	if err != nil {
-		logger.L.Fatal("Failed to initialize database")
-		model.Error(c, 2000, "数据库连接失败")
-		return
-	}
-	userService := user2.NewUserService(db)
-	// 注册时校验图形验证码
-	user, err := userService.RegisterUser(req.Phone, req.Password)
+	user, err := h.app.Users.RegisterUser(req.Phone, req.Password)
 	if err != nil {
 		model.Error(c, 1002, "注册失败："+err.Error())
 		return
@@ -155,7 +175,7 @@ func register(c *gin.Context) {
 	model.Success(c, gin.H{"user": user})
 }
 
-func refreshCaptcha(c *gin.Context) {
+func (h *Handler) refreshCaptcha(c *gin.Context) {
 	type Request struct {
 		OldID string `json:"old_id" binding:"required"`
 	}
@@ -169,7 +189,7 @@ func refreshCaptcha(c *gin.Context) {
 		return
 	}
 
-	service := captcha.NewCaptService()
+	service := h.app.Captcha
 
 	// 先把老的验证码删除掉
 	service.GetCodeAnswer(req.OldID) // 调用一次 Get，会把验证码拿出来（并清除）
@@ -190,9 +210,8 @@ func refreshCaptcha(c *gin.Context) {
 	})
 }
 
-func getCaptcha(c *gin.Context) {
-	service := captcha.NewCaptService()
-	id, b64s, err := service.CreateCode()
+func (h *Handler) getCaptcha(c *gin.Context) {
+	id, b64s, err := h.app.Captcha.CreateCode()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "生成验证码失败",
@@ -205,37 +224,7 @@ func getCaptcha(c *gin.Context) {
 	})
 }
 
-func getAccessToken(c *gin.Context) {
-	log := logger.WithRequest(c.Request)
-	// 验证请求方法
-	if c.Request.Method != http.MethodPost {
-		log.Error("Invalid request method")
-		c.JSON(http.StatusMethodNotAllowed, model.MethodNotAllowed)
-		return
-	}
-	var req model.TokenRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, model.InvalidRequest)
-		return
-	}
-	authService := auth.NewAuthService()
-	resp, err := authService.GetAccessToken(&req)
-	if err != nil {
-		log.Error("Failed to get accessToken", logger.WithError(err))
-		c.JSON(http.StatusBadRequest, model.AuthFailed)
-		return
-	}
-	accessToken := resp.AccessToken
-	fmt.Println("accessToken:" + accessToken)
-	c.JSON(http.StatusOK, model.Resp{
-		Code:    0,
-		Msg:     "Success",
-		Data:    resp,
-		Content: accessToken,
-	})
-}
-
-func transformV1(c *gin.Context) {
+func (h *Handler) transformV1(c *gin.Context) {
 	start := time.Now()
 	log := logger.WithRequest(c.Request)
 
@@ -262,93 +251,91 @@ func transformV1(c *gin.Context) {
 		model.Error(c, 1002, "解析请求体错误")
 		return
 	}
-	fmt.Println(req)
 	// 验证请求字段
-	if err := validateRequestFields(&req); err != nil {
+	if err := validateRequestFields(&req, h.app.Config.Current().ImgConfig); err != nil {
 		log.Error("Request validation failed", logger.WithError(err))
 		model.Error(c, 2001, "Request validation failed")
 		return
 	}
 
-	// 选择处理流程
-	var handler func(*gin.Context, *model.Req) (string, string, []string, error)
-	if req.IsFile {
-		//处理pdf、docx转置的飞书文档
-		handler = handleFileTransform
-	} else {
-		handler = handleDocTransform
-	}
-	//1. 没有markdown处理
-	markdown, tittle, imgTokens, err := handler(c, &req)
-	fmt.Println("")
-	fmt.Printf("markdown:%v", markdown)
-	if tittle == "" {
-		tittle = "default"
-	}
+	markdown, tittle, images, err := h.runTransform(c, &req)
 	// 执行处理逻辑
 	if err != nil {
 		log.Error("Processing failed", zap.Error(err))
 		// 处理自定义错误类型
-		if resp, ok := err.(*model.ErrorResponse); ok {
-			model.Error(c, resp.Code, resp.Message)
-		} else {
-			model.Error(c, 1007, "执行解析失败")
+		resp, ok := err.(*model.ErrorResponse)
+		if !ok {
+			resp = &model.ErrorResponse{Code: 1007, Message: "执行解析失败"}
 		}
+		// 严格模式下图片失败时一并返回每张图片的处理结果
+		if images != nil {
+			c.JSON(http.StatusOK, model.Resp{Code: resp.Code, Msg: resp.Message, Data: gin.H{"images": images}})
+			return
+		}
+		model.Error(c, resp.Code, resp.Message)
 		return
 	}
-	db, err := database.InitializeDB(DSN) // 更新为你的数据库连接信息
-	histtroyService := services.NewTransformService(db)
-	userID, err := strconv.Atoi(req.Id)
+	model.Success(c, gin.H{
+		"markdown": markdown,
+		"Title":    tittle,
+		"images":   images,
+	})
+	// 记录QPS
+	metrics.QPS.WithLabelValues(c.Request.Method, c.FullPath()).Inc()
+}
+
+// runTransform 转换文档，需要时下载图片，并保存历史记录，返回最终的 Markdown、标题与每张图片的处理结果；
+// 进度通过请求上下文中的 progress.Reporter 上报
+func (h *Handler) runTransform(c *gin.Context, req *model.Req) (string, string, []model.ImageResult, error) {
+	log := logger.WithRequest(c.Request)
+
+	// 选择处理流程
+	var handler func(*gin.Context, *model.Req, UserToken) (string, string, []string, error)
+	if req.IsFile {
+		//处理pdf、docx转置的飞书文档
+		handler = h.handleFileTransform
+	} else {
+		handler = h.handleDocTransform
+	}
+	//1. 没有markdown处理
+	userToken := h.userToken(c, h.documentDomain(req.Url), req.UserAccessToken)
+	markdown, tittle, imgTokens, err := handler(c, req, userToken)
 	if err != nil {
-		log.Error("Invalid user ID", zap.Error(err))
-		model.Error(c, 1001, "用户ID格式不正确")
-		return
+		return "", "", nil, err
 	}
+	if tittle == "" {
+		tittle = "default"
+	}
+	result := markdown
+	var images []model.ImageResult
+	// 历史记录归属于通过 JWT 或 API Key 认证的用户
+	userID := c.GetInt("userID")
+	ctx := c.Request.Context()
 	if req.WithImageDownload {
-		domain, _, _, _ := parseDocumentURL(req.Url)
-		cfg := config.LoadConfig()
-		redis := cache.InitRedisClient("local")
-		storageClient, err := storage.InitStorageClient(*cfg.Storage)
+		domain, _, _, _ := h.parseDocumentURL(req.Url)
+		processor, err := h.app.ImageProcessor(domain)
 		if err != nil {
-			log.Error("IntiStorage client failed", zap.Error(err))
+			return "", "", nil, domainError(err)
 		}
-		client := feishu.NewClient(cfg.Feishu.AppID, cfg.Feishu.AppSecret, domain)
-		processor := img.NewProcessor(*redis, storageClient, *client, *cfg.ImgConfig)
-		imgTokens := imgTokens
-		fmt.Printf("ImgToken:%v", imgTokens)
-		// 图片处理方法
-		resultMarkdown, err := processor.ProcessImages(c.Request.Context(), markdown, imgTokens, req)
-		if err != nil {
+		if req.UserAccessToken, err = userToken(ctx); err != nil {
+			log.Error("Failed to get feishu user token", zap.Error(err))
+			return "", "", nil, err
+		}
+		// 图片处理方法，严格模式下有图片失败时不保存历史记录
+		if result, images, err = processor.ProcessImages(ctx, markdown, imgTokens, userID, *req); err != nil {
 			log.Error("Image processing failed", zap.Error(err))
-			model.Error(c, 1003, "图片处理失败")
-			return
+			return "", "", images, &model.ErrorResponse{Code: 1003, Message: "图片处理失败", Detail: err.Error()}
 		}
-		histtroyService.CreateTransform(userID, req.Url, resultMarkdown, tittle)
-		// 返回处理后的结果
-		model.Success(c, gin.H{
-			"markdown": resultMarkdown,
-			"Title":    tittle,
-		})
-
+	}
+	if transform, err := h.app.Transforms.CreateTransformWithImages(userID, req.Url, result, tittle, images); err != nil {
+		log.Error("Failed to save transform history", zap.Error(err))
 	} else {
-		histtroyService.CreateTransform(userID, req.Url, markdown, tittle)
-		model.Success(c, gin.H{
-			"markdown": markdown,
-			"Title":    tittle,
-		}) // 返回未处理图片的 markdown
-	}
-	//写入文件
-	err = writeContentToMDFile(markdown)
-	fmt.Printf("resultMarkdown:%v", markdown)
-	if err != nil {
-		logger.L.Info("failed to write .md file: %v", zap.Error(err))
+		progress.Report(ctx, progress.Event{Stage: progress.StageSaved, ID: transform.ID})
 	}
-	// 记录QPS
-	metrics.QPS.WithLabelValues(c.Request.Method, c.FullPath()).Inc()
-	return
+	return result, tittle, images, nil
 }
 
-func handleDocTransform(c *gin.Context, req *model.Req) (string, string, []string, error) {
+func (h *Handler) handleDocTransform(c *gin.Context, req *model.Req, userToken UserToken) (string, string, []string, error) {
 	start := time.Now()
 
 	// 记录指标
@@ -360,19 +347,17 @@ func handleDocTransform(c *gin.Context, req *model.Req) (string, string, []strin
 	}()
 
 	// 处理文档转换
-	markdown, tittle, imgTokens, err := handleURLArgument(c, req)
+	markdown, tittle, imgTokens, err := h.handleURLArgument(c, req, userToken)
 	if err != nil {
-		// 特定错误处理
-		if strings.Contains(err.Error(), "Invalid access token") ||
-			strings.Contains(err.Error(), "Invalid URL format") {
-			metrics.ErrorRequests.WithLabelValues(c.Request.Method, c.FullPath()).Inc()
-			return "", "", nil, &model.ErrorResponse{
-				Code:    http.StatusBadRequest,
-				Message: "Unsupported document type",
-			}
+		// 需要用户重新授权时原样返回，提示前端重新关联飞书账号
+		if err == errReauthorize {
+			return "", "", nil, errReauthorize
 		}
-
 		metrics.ErrorRequests.WithLabelValues(c.Request.Method, c.FullPath()).Inc()
+		// handleURLArgument 已将飞书错误转换为带状态码的 ErrorResponse
+		if resp, ok := err.(*model.ErrorResponse); ok {
+			return "", "", nil, resp
+		}
 		return "", "", nil, &model.ErrorResponse{
 			Code:    http.StatusInternalServerError,
 			Message: "Document transformation failed",
@@ -389,9 +374,9 @@ func handleDocTransform(c *gin.Context, req *model.Req) (string, string, []strin
 	return markdown, tittle, imgTokens, nil
 }
 
-// DocHandler 文档处理接口
+// DocHandler 文档处理接口，client 为文档所在域名的飞书客户端
 type DocHandler interface {
-	Process(ctx context.Context, token, domain, userAccessToken string, downLoadImg bool, req model.Req) ([]byte, []string, error)
+	Process(ctx context.Context, client *feishu.Client, token string, userToken UserToken, downLoadImg bool, req model.Req) ([]byte, []string, error)
 }
 
 // 文档处理器注册表
@@ -406,7 +391,7 @@ var docHandlers = map[string]DocHandler{
 	"bitable": &BitableHandler{},
 }
 
-func handleURLArgument(c *gin.Context, req *model.Req) (string, string, []string, error) {
+func (h *Handler) handleURLArgument(c *gin.Context, req *model.Req, userToken UserToken) (string, string, []string, error) {
 	start := time.Now()
 	log := logger.WithRequest(c.Request)
 	ctx := c.Request.Context()
@@ -419,7 +404,7 @@ func handleURLArgument(c *gin.Context, req *model.Req) (string, string, []string
 	}()
 
 	// 1. 解析URL获取文档类型和token
-	domain, docType, token, err := parseDocumentURL(req.Url)
+	domain, docType, token, err := h.parseDocumentURL(req.Url)
 	if err != nil {
 		return "", "", nil, &model.ErrorResponse{
 			Code:    http.StatusBadRequest,
@@ -439,7 +424,20 @@ func handleURLArgument(c *gin.Context, req *model.Req) (string, string, []string
 	}
 
 	// 3. 处理文档内容
-	content, imgTokens, err := handler.Process(ctx, token, domain, req.UserAccessToken, req.WithImageDownload, *req)
+	t, err := h.app.Tenants.Resolve(domain)
+	if err != nil {
+		return "", "", nil, domainError(err)
+	}
+	client, err := h.feishuClient(domain)
+	if err != nil {
+		return "", "", nil, err
+	}
+	content, imgTokens, err := handler.Process(ctx, client, token, userToken, req.WithImageDownload, *req)
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	metrics.TenantTransforms.WithLabelValues(t.Name, docType, outcome).Inc()
 	if err != nil {
 		return "", "", nil, wrapProcessingError(err, docType)
 	}
@@ -450,17 +448,14 @@ func handleURLArgument(c *gin.Context, req *model.Req) (string, string, []string
 	if err := json.Unmarshal(content, &result); err != nil {
 		return "", "", nil, fmt.Errorf("failed to parse sheet response: %w", err)
 	}
-	fmt.Printf("handleURLArgument:content:%v", result.Markdown)
 	return result.Markdown, result.SheetTitle, imgTokens, nil
 }
 
 // parseDocumentURL 解析飞书文档URL
-func parseDocumentURL(url string) (domain, docType, token string, err error) {
+func (h *Handler) parseDocumentURL(url string) (domain, docType, token string, err error) {
 	// 匹配飞书文档URL格式
 	// 示例：https://xxx.feishu.cn/docx/ABC123 或 https://xxx.larksuite.com/docs/ABC123
-	pattern := `^https://[a-zA-Z0-9-]+.(feishu.cn|larksuite.com|f.mioffice.cn)/(doc|docs|docx|wiki|sheets|base|sheet|bitable)/([a-zA-Z0-9]+)`
-	reg := regexp.MustCompile(pattern)
-	matches := reg.FindStringSubmatch(url)
+	matches := h.documentPattern.FindStringSubmatch(url)
 
 	if matches == nil || len(matches) != 4 {
 		return "", "", "", fmt.Errorf("Invalid feishu/larksuite URL format\n")
@@ -482,26 +477,34 @@ func normalizeDocType(rawType string) string {
 
 // wrapProcessingError 包装处理错误
 func wrapProcessingError(err error, docType string) error {
+	if resp, ok := err.(*model.ErrorResponse); ok {
+		return resp
+	}
+	resp := &model.ErrorResponse{Code: feishu.HTTPStatus(err)}
 	switch {
-	case strings.Contains(err.Error(), "permission denied"):
-		return &model.ErrorResponse{
-			Code:    http.StatusForbidden,
-			Message: "Access denied",
-			Detail:  fmt.Sprintf("No permission to access %s document", docType),
-		}
-	case strings.Contains(err.Error(), "not found"):
-		return &model.ErrorResponse{
-			Code:    http.StatusNotFound,
-			Message: "Document not found",
-			Detail:  fmt.Sprintf("%s document not exist or deleted", docType),
-		}
+	case errors.Is(err, feishu.ErrNoPermission):
+		resp.Message = "Access denied"
+		resp.Detail = fmt.Sprintf("No permission to access %s document", docType)
+	case errors.Is(err, feishu.ErrNotFound):
+		resp.Message = "Document not found"
+		resp.Detail = fmt.Sprintf("%s document not exist or deleted", docType)
+	case errors.Is(err, feishu.ErrRateLimited):
+		resp.Message = "Feishu rate limit exceeded"
+		resp.Detail = "Too many requests to feishu, please retry later"
+	case errors.Is(err, feishu.ErrTokenExpired):
+		resp.Message = "Feishu access token expired"
+		resp.Detail = "Please authorize feishu again"
+	case errors.Is(err, feishu.ErrInvalidToken):
+		resp.Message = "Invalid feishu access token"
+		resp.Detail = "Please authorize feishu again"
+	case errors.Is(err, feishu.ErrServer):
+		resp.Message = "Feishu service unavailable"
+		resp.Detail = fmt.Sprintf("Failed to process %s document: %v", docType, err)
 	default:
-		return &model.ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: "Document processing failed",
-			Detail:  fmt.Sprintf("Failed to process %s document: %v", docType, err),
-		}
+		resp.Message = "Document processing failed"
+		resp.Detail = fmt.Sprintf("Failed to process %s document: %v", docType, err)
 	}
+	return resp
 }
 
 // ========== 具体文档处理器实现 ==========
@@ -509,20 +512,19 @@ func wrapProcessingError(err error, docType string) error {
 // DocHandlerImpl 旧文档处理器
 type DocHandlerImpl struct{}
 
-func (h *DocHandlerImpl) Process(ctx context.Context, token, domain, userAccessToken string, downLoadImg bool, req model.Req) ([]byte, []string, error) {
-	cfg := config.LoadConfig()
-	client := feishu.NewClient(cfg.Feishu.AppID, cfg.Feishu.AppSecret, domain)
-
+func (h *DocHandlerImpl) Process(ctx context.Context, client *feishu.Client, token string, userToken UserToken, downLoadImg bool, req model.Req) ([]byte, []string, error) {
+	userAccessToken, err := userToken(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
 	result, err := client.GetDocumentContent(ctx, token, userAccessToken)
+	if err != nil {
+		return nil, nil, err
+	}
 	resp := map[string]string{
 		"markdown": result.Markdown,
 		"docTitle": result.DocTitle,
 	}
-	//err = writeContentToMDFile(result.Markdown)
-	//if err != nil {
-	//	log.Printf("failed to write .md file: %v", err)
-	//	// 非致命错误，继续执行
-	//}
 	jsonBytes, err := json.Marshal(resp)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to marshal doc result: %w", err)
@@ -534,49 +536,42 @@ func (h *DocHandlerImpl) Process(ctx context.Context, token, domain, userAccessT
 // WikiHandler 处理知识库文档
 type WikiHandler struct{}
 
-func (h *WikiHandler) Process(ctx context.Context, token, domain, userAccessToken string, downLoadImg bool, req model.Req) ([]byte, []string, error) {
-	cfg := config.LoadConfig()
-	client := feishu.NewClient(cfg.Feishu.AppID, cfg.Feishu.AppSecret, domain)
-
+func (h *WikiHandler) Process(ctx context.Context, client *feishu.Client, token string, userToken UserToken, downLoadImg bool, req model.Req) ([]byte, []string, error) {
+	userAccessToken, err := userToken(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
 	// 获取知识库节点信息
 	node, err := client.GetWikiNodeInfo(ctx, token, userAccessToken)
-	fmt.Printf("node:%v", node.ObjType)
 	if err != nil {
 		return nil, nil, err
 	}
-	fmt.Printf("Type:%v", node.ObjType)
 	handler, exists := docHandlers[node.ObjType]
 	if !exists {
 		return nil, nil, fmt.Errorf("暂不支持处理 %s 类型文档", node.ObjType)
 	}
 
 	// 转发到实际文档处理器
-	return handler.Process(ctx, node.ObjToken, domain, userAccessToken, downLoadImg, req)
+	return handler.Process(ctx, client, node.ObjToken, userToken, downLoadImg, req)
 }
 
 // SheetHandler 表格处理器
 type SheetHandler struct{}
 
-func (s *SheetHandler) Process(ctx context.Context, token, domain, userAccessToken string, downLoadImg bool, req model.Req) ([]byte, []string, error) {
-	cfg := config.LoadConfig()
-	client := feishu.NewClient(cfg.Feishu.AppID, cfg.Feishu.AppSecret, domain)
-	fmt.Printf("token:%s,userAccessToken:%s,Url:%s", token, userAccessToken, req.Url)
+func (s *SheetHandler) Process(ctx context.Context, client *feishu.Client, token string, userToken UserToken, downLoadImg bool, req model.Req) ([]byte, []string, error) {
+	userAccessToken, err := userToken(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
 	result, err := client.GetSheetsContent(ctx, token, userAccessToken, req.Url)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get document content: %w", err)
 	}
-	fmt.Printf("result:%v", result)
 
 	resp := map[string]string{
 		"markdown":   result.Markdown,
 		"sheetTitle": result.SheetTitle,
 	}
-	//生成 .md 文件并写入内容
-	err = writeContentToMDFile(result.Markdown)
-	if err != nil {
-		log.Printf("failed to write .md file: %v", err)
-		// 非致命错误，继续执行
-	}
 	jsonBytes, err := json.Marshal(resp)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to marshal sheet result: %w", err)
@@ -585,69 +580,15 @@ func (s *SheetHandler) Process(ctx context.Context, token, domain, userAccessTok
 	return jsonBytes, result.ImgTokens, nil
 }
 
-func writeContentToMDFile(content string) error {
-	fmt.Println("Start writeContentToMDFile")
-	// 从 content 中提取标题
-	re := regexp.MustCompile(`(?m)^# (.+)$`)
-	matches := re.FindStringSubmatch(content)
-	title := "Untitled"
-	if len(matches) >= 2 {
-		title = matches[1]
-	}
-	// 移除非法文件名字符
-	title = sanitizeFileName(title)
-	fileName := fmt.Sprintf("%s.md", title)
-
-	// 获取当前目录路径
-	currentDir, err := os.Getwd()
-	if err != nil {
-		fmt.Printf("Failed to get current directory: %v\n", err)
-		return err
-	}
-	fmt.Println("Current directory:", currentDir)
-
-	// 确保 testmd 文件夹存在
-	testDir := filepath.Join(currentDir, "testmd")
-	if _, err := os.Stat(testDir); os.IsNotExist(err) {
-		fmt.Println("Creating directory:", testDir)
-		err = os.Mkdir(testDir, 0755)
-		if err != nil {
-			return fmt.Errorf("failed to create directory %s: %w", testDir, err)
-		}
-	} else {
-		fmt.Println("Directory already exists:", testDir)
-	}
-
-	// 检查 content 是否为空
-	if len(content) == 0 {
-		return fmt.Errorf("content is empty, cannot write to file")
-	}
-	fmt.Println("Content length:", len(content))
-
-	// 写入文件
-	filePath := filepath.Join(testDir, fileName)
-	err = os.WriteFile(filePath, []byte(content), 0644)
-	if err != nil {
-		return fmt.Errorf("failed to write content to file %s: %w", filePath, err)
-	}
-
-	fmt.Printf("File successfully written: %s\n", filePath)
-	return nil
-}
-func sanitizeFileName(fileName string) string {
-	// 移除文件名中的非法字符
-	illegalChars := regexp.MustCompile(`[\/:*?"<>|]`)
-	return illegalChars.ReplaceAllString(fileName, "")
-}
-
 // BitableHandler 多为表格处理器
 type BitableHandler struct {
 }
 
-func (s *BitableHandler) Process(ctx context.Context, token, domain, userAccessToken string, downLoadImg bool, req model.Req) ([]byte, []string, error) {
-	cfg := config.LoadConfig()
-	client := feishu.NewClient(cfg.Feishu.AppID, cfg.Feishu.AppSecret, domain)
-
+func (s *BitableHandler) Process(ctx context.Context, client *feishu.Client, token string, userToken UserToken, downLoadImg bool, req model.Req) ([]byte, []string, error) {
+	userAccessToken, err := userToken(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
 	sheet, err := client.GetBitablesContent(ctx, token, userAccessToken, req.Url)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get document content: %w", err)
@@ -659,40 +600,26 @@ func (s *BitableHandler) Process(ctx context.Context, token, domain, userAccessT
 // ========== 辅助函数 ==========
 
 // validateRequestFields 验证请求字段
-func validateRequestFields(req *model.Req) error {
-	if !req.IsFile {
-		if req.Id == "" || req.Url == "" {
+func validateRequestFields(req *model.Req, imgConfig *conf.ImgConfig) error {
+	if !req.IsFile && req.Url == "" {
+		return &model.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Missing required fields",
+		}
+	}
+	if req.ImageProcess != nil {
+		if err := img.ValidateProcess(*req.ImageProcess, imgConfig.CWebPPath); err != nil {
 			return &model.ErrorResponse{
 				Code:    http.StatusBadRequest,
-				Message: "Missing required fields",
+				Message: "Invalid image_process",
+				Detail:  err.Error(),
 			}
 		}
-
-		//if _, ok := accessKeySet[req.AccessKey]; !ok {
-		//	return &model.ErrorResponse{
-		//		Code:    http.StatusUnauthorized,
-		//		Message: "Invalid access key",
-		//	}
-		//}
 	}
 	return nil
 }
 
-// 定义一个全局的set，用来存储access_key，并初始化
-var accessKeySet = map[string]struct{}{
-	"7r9dWfGq2L": {},
-	"Y6tE5z4P1X": {},
-	"3yC7sB2eRv": {},
-	"G5D4hJ6j8S": {},
-	"1mV9uN0wFq": {},
-	"T4xY3bW2dV": {},
-	"K7lO6aP3eI": {},
-	"Z8mN7pB9cX": {},
-	"D2fA4sG6rH": {},
-	"Q9wE8rT6yU": {},
-}
-
-func handleFileTransform(c *gin.Context, req *model.Req) (string, string, []string, error) {
+func (h *Handler) handleFileTransform(c *gin.Context, req *model.Req, userToken UserToken) (string, string, []string, error) {
 	start := time.Now()
 
 	// 记录指标
@@ -703,8 +630,7 @@ func handleFileTransform(c *gin.Context, req *model.Req) (string, string, []stri
 		).Observe(time.Since(start).Seconds())
 	}()
 
-	reg := regexp.MustCompile("^https://[a-zA-Z0-9-]+.(feishu.cn|larksuite.com|f.mioffice.cn)/(doc|docs|docx|wiki|file)/([a-zA-Z0-9]+)")
-	matchResult := reg.FindStringSubmatch(req.Url)
+	matchResult := h.filePattern.FindStringSubmatch(req.Url)
 
 	if matchResult == nil || len(matchResult) != 4 {
 		return "", "", nil, &model.ErrorResponse{
@@ -713,14 +639,14 @@ func handleFileTransform(c *gin.Context, req *model.Req) (string, string, []stri
 		}
 	}
 
-	cfg := config.LoadConfig()
-	// 初始化飞书客户端
 	domain := matchResult[1]
 	docType := matchResult[2]
 	docToken := matchResult[3]
-	fmt.Printf("Debug: Extracted domain: %s, docType: %s, docToken: %s\n", domain, docType, docToken)
 
-	client := feishu.NewClient(cfg.Feishu.AppID, cfg.Feishu.AppSecret, domain)
+	client, err := h.feishuClient(domain)
+	if err != nil {
+		return "", "", nil, err
+	}
 
 	logger.Info("handle info: ",
 		zap.String("domain", domain),
@@ -729,7 +655,11 @@ func handleFileTransform(c *gin.Context, req *model.Req) (string, string, []stri
 	)
 
 	// 下载文件
-	data, err := client.DownloadFile(docToken, req.UserAccessToken)
+	userAccessToken, err := userToken(c.Request.Context())
+	if err != nil {
+		return "", "", nil, err
+	}
+	data, err := client.DownloadFile(docToken, userAccessToken)
 	if err != nil {
 		metrics.ErrorRequests.WithLabelValues(c.Request.Method, c.FullPath()).Inc()
 		return "", "", nil, &model.ErrorResponse{
@@ -765,15 +695,31 @@ func handleFileTransform(c *gin.Context, req *model.Req) (string, string, []stri
 	return string(contentBytes), fileNameWithoutExt, nil, nil
 }
 
-func healthCheck(c *gin.Context) {
-	logger.Info("Health check endpoint called")
-	c.JSON(http.StatusOK, gin.H{
-		"status": "ok",
+// healthCheck 报告依赖的可用状态：数据库不可用时返回 503；Redis 不可用时服务降级为进程内缓存与限流，
+// 仍返回 200，status 为 degraded
+func (h *Handler) healthCheck(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+	checks := gin.H{"database": "up", "redis": "up"}
+	status, code := "ok", http.StatusOK
+	if !h.app.Cache.Healthy() {
+		checks["redis"] = "down"
+		status = "degraded"
+	}
+	if err := h.app.DB.PingContext(ctx); err != nil {
+		logger.WithRequest(c.Request).Error("Database health check failed", zap.Error(err))
+		checks["database"] = "down"
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{
+		"status": status,
+		"ready":  code == http.StatusOK,
+		"checks": checks,
 	})
 }
 
 // uploadFile 处理文件上传和解析
-func uploadFile(c *gin.Context) {
+func (h *Handler) uploadFile(c *gin.Context) {
 	// 1. 获取上传的文件
 	file, err := c.FormFile("file")
 	if err != nil {
@@ -784,10 +730,10 @@ func uploadFile(c *gin.Context) {
 		return
 	}
 
-	// 2. 创建保存文件的目录（如果不存在）
-	uploadDir := "uploads"
+	// 2. 创建保存文件的目录（如果不存在），每个用户的文件保存在各自的目录下
+	uploadDir := filepath.Join("uploads", strconv.Itoa(c.GetInt("userID")))
 	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
-		if err := os.Mkdir(uploadDir, 0755); err != nil {
+		if err := os.MkdirAll(uploadDir, 0755); err != nil {
 			logger.L.Error("Failed to create upload directory", zap.Error(err))
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "服务器内部错误",
@@ -796,8 +742,8 @@ func uploadFile(c *gin.Context) {
 		}
 	}
 
-	// 3. 保存文件到服务器
-	filePath := filepath.Join(uploadDir, file.Filename)
+	// 3. 保存文件到服务器，文件名去掉客户端提供的目录
+	filePath := filepath.Join(uploadDir, filepath.Base(filepath.Clean("/"+file.Filename)))
 	if err := c.SaveUploadedFile(file, filePath); err != nil {
 		logger.L.Error("Failed to save file", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
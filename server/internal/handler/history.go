@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"feishu2md/server/internal/logger"
+	"feishu2md/server/internal/model"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+)
+
+// listHistory 分页查询当前用户的历史记录
+func (h *Handler) listHistory(c *gin.Context) {
+	var query model.HistoryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		model.ParamError(c, err)
+		return
+	}
+	page, err := h.app.Transforms.ListHistory(c.GetInt("userID"), query)
+	if err != nil {
+		if _, ok := err.(*model.ErrorResponse); !ok {
+			logger.WithRequest(c.Request).Error("Failed to list history", zap.Error(err))
+		}
+		respondError(c, err)
+		return
+	}
+	model.Success(c, page)
+}
+
+// getHistoryItem 获取当前用户的一条历史记录
+func (h *Handler) getHistoryItem(c *gin.Context) {
+	record, ok := h.ownedTransform(c)
+	if !ok {
+		return
+	}
+	model.Success(c, record)
+}
+
+// deleteHistoryItem 删除当前用户的一条历史记录
+func (h *Handler) deleteHistoryItem(c *gin.Context) {
+	record, ok := h.ownedTransform(c)
+	if !ok {
+		return
+	}
+	if err := h.app.Transforms.DeleteTransform(record.ID); err != nil {
+		logger.WithRequest(c.Request).Error("Failed to delete history", zap.Error(err))
+		model.Error(c, 2002, "删除历史记录失败")
+		return
+	}
+	model.Success(c, gin.H{"id": record.ID})
+}
+
+// ownedTransform 读取路径中的记录 ID，记录不存在或不属于当前用户时返回 404
+func (h *Handler) ownedTransform(c *gin.Context) (*model.Transform, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		model.ParamError(c, err)
+		return nil, false
+	}
+	record, err := h.app.Transforms.GetTransform(id)
+	if err != nil || record.UserID != c.GetInt("userID") {
+		model.Error(c, http.StatusNotFound, "历史记录不存在")
+		return nil, false
+	}
+	return record, true
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"feishu2md/server/internal/feishu"
+	"feishu2md/server/internal/logger"
+	"feishu2md/server/internal/model"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+const defaultImportDomain = "feishu.cn"
+
+// importMarkdown 将 Markdown 导入为飞书文档，指定 url 时替换该文档内容
+func (h *Handler) importMarkdown(c *gin.Context) {
+	log := logger.WithRequest(c.Request)
+	ctx := c.Request.Context()
+
+	var req model.ImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		model.ParamError(c, err)
+		return
+	}
+
+	domain := req.Domain
+	if domain == "" {
+		domain = defaultImportDomain
+	}
+	var docType, token string
+	if req.Url != "" {
+		var err error
+		if domain, docType, token, err = h.parseDocumentURL(req.Url); err != nil {
+			model.Error(c, 1002, "Invalid document URL")
+			return
+		}
+	}
+	// 新建文档的域名来自请求，不属于任何租户时不能将令牌发往该域名
+	client, err := h.feishuClient(domain)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	userAccessToken, err := h.userToken(c, domain, req.UserAccessToken)(ctx)
+	if err != nil {
+		log.Error("Failed to get feishu user token", zap.Error(err))
+		respondError(c, err)
+		return
+	}
+	req.UserAccessToken = userAccessToken
+
+	// 知识库节点需要先解析出实际的文档
+	if docType == "wiki" {
+		node, err := client.GetWikiNodeInfo(ctx, token, req.UserAccessToken)
+		if err != nil {
+			log.Error("Failed to get wiki node", zap.Error(err))
+			respondError(c, wrapProcessingError(err, docType))
+			return
+		}
+		docType, token = node.ObjType, node.ObjToken
+	}
+	if req.Url != "" && docType != "docx" {
+		model.Error(c, http.StatusUnprocessableEntity, fmt.Sprintf("Doctype '%s' does not support import", docType))
+		return
+	}
+
+	result, err := client.ImportMarkdown(ctx, []byte(req.Markdown), feishu.ImportOptions{
+		DocumentID:      token,
+		FolderToken:     req.FolderToken,
+		Title:           req.Title,
+		UserAccessToken: req.UserAccessToken,
+	})
+	if err != nil {
+		log.Error("Markdown import failed", zap.Error(err))
+		respondError(c, wrapProcessingError(err, "docx"))
+		return
+	}
+
+	url := req.Url
+	if url == "" {
+		url = fmt.Sprintf("https://%s/docx/%s", domain, result.DocumentID)
+	}
+	log.Info("Markdown imported",
+		zap.String("document_id", result.DocumentID),
+		zap.Int("blocks", result.BlockCount),
+		zap.Int("warnings", len(result.Warnings)))
+	model.Success(c, &model.ImportResult{
+		DocumentID: result.DocumentID,
+		Url:        url,
+		RevisionID: result.RevisionID,
+		BlockCount: result.BlockCount,
+		Warnings:   result.Warnings,
+	})
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"feishu2md/server/internal/feishu"
+	"feishu2md/server/internal/logger"
+	"feishu2md/server/internal/model"
+	"feishu2md/server/internal/service/revision"
+	services "feishu2md/server/internal/service/transform"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// diffRevisions 导出飞书文档的两个版本并返回差异
+func (h *Handler) diffRevisions(c *gin.Context) {
+	log := logger.WithRequest(c.Request)
+	ctx := c.Request.Context()
+
+	var req model.RevisionDiffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		model.ParamError(c, err)
+		return
+	}
+	userID, ok := c.Get("userID")
+	if !ok {
+		model.Error(c, http.StatusUnauthorized, "未授权")
+		return
+	}
+
+	userAccessToken, err := h.userToken(c, h.documentDomain(req.Url), req.UserAccessToken)(ctx)
+	if err != nil {
+		log.Error("Failed to get feishu user token", zap.Error(err))
+		respondError(c, err)
+		return
+	}
+	req.UserAccessToken = userAccessToken
+
+	domain, docType, token, err := h.parseDocumentURL(req.Url)
+	if err != nil {
+		model.Error(c, 1002, "Invalid document URL")
+		return
+	}
+	client, err := h.feishuClient(domain)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// 知识库节点需要先解析出实际的文档
+	if docType == "wiki" {
+		node, err := client.GetWikiNodeInfo(ctx, token, req.UserAccessToken)
+		if err != nil {
+			log.Error("Failed to get wiki node", zap.Error(err))
+			respondError(c, wrapProcessingError(err, docType))
+			return
+		}
+		docType, token = node.ObjType, node.ObjToken
+	}
+	if docType != "docx" {
+		model.Error(c, http.StatusUnprocessableEntity, fmt.Sprintf("Doctype '%s' does not support revisions", docType))
+		return
+	}
+
+	revisions, err := h.app.Revisions(domain)
+	if err != nil {
+		respondError(c, domainError(err))
+		return
+	}
+	result, from, to, err := revisions.Diff(ctx, token, req.UserAccessToken, req.FromRevision, req.ToRevision)
+	if err != nil {
+		log.Error("Revision diff failed", zap.Error(err))
+		if _, ok := err.(*model.ErrorResponse); !ok {
+			err = wrapProcessingError(err, docType)
+		}
+		respondError(c, err)
+		return
+	}
+
+	if req.SaveHistory {
+		historyService := h.app.Transforms
+		for _, item := range []struct {
+			rev *feishu.DocumentRevision
+			id  *int
+		}{{from, &result.FromHistoryID}, {to, &result.ToHistoryID}} {
+			transform, err := saveRevision(historyService, userID.(int), req.Url, item.rev)
+			if err != nil {
+				log.Error("Failed to save revision history", zap.Error(err))
+				model.Error(c, 2002, "保存历史记录失败")
+				return
+			}
+			*item.id = transform.ID
+		}
+	}
+
+	model.Success(c, result)
+}
+
+// saveRevision 将导出的版本写入历史记录，同一版本只保存一次
+func saveRevision(service *services.TransformService, userID int, url string, rev *feishu.DocumentRevision) (*model.Transform, error) {
+	content := rev.Content
+	existing, err := service.FindTransformByRevision(userID, url, content.RevisionID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+	return service.CreateTransformRevision(userID, url, content.Markdown, content.DocTitle, content.RevisionID)
+}
+
+// diffHistory 对比两条历史导出记录
+func (h *Handler) diffHistory(c *gin.Context) {
+	var req model.HistoryDiffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		model.ParamError(c, err)
+		return
+	}
+	userID, ok := c.Get("userID")
+	if !ok {
+		model.Error(c, http.StatusUnauthorized, "未授权")
+		return
+	}
+
+	service := h.app.Transforms
+
+	var records [2]*model.Transform
+	for i, id := range []int{req.FromID, req.ToID} {
+		record, err := service.GetTransform(id)
+		if err != nil || record.UserID != userID.(int) {
+			model.Error(c, http.StatusNotFound, fmt.Sprintf("历史记录 %d 不存在", id))
+			return
+		}
+		records[i] = record
+	}
+
+	from, to := records[0], records[1]
+	model.Success(c, &model.RevisionDiffResult{
+		Title:         to.Tittle,
+		FromRevision:  from.RevisionID,
+		ToRevision:    to.RevisionID,
+		UnifiedDiff:   revision.UnifiedDiff(from.Result, to.Result, historyLabel(from), historyLabel(to)),
+		FromHistoryID: from.ID,
+		ToHistoryID:   to.ID,
+	})
+}
+
+func historyLabel(t *model.Transform) string {
+	if t.RevisionID > 0 {
+		return fmt.Sprintf("history %d (revision %d)", t.ID, t.RevisionID)
+	}
+	return fmt.Sprintf("history %d", t.ID)
+}
+
+// respondError 按 ErrorResponse 返回错误
+func respondError(c *gin.Context, err error) {
+	if resp, ok := err.(*model.ErrorResponse); ok {
+		model.Error(c, resp.Code, resp.Message)
+		return
+	}
+	model.Error(c, http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"errors"
+	"feishu2md/server/internal/logger"
+	"feishu2md/server/internal/model"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"strings"
+)
+
+// searchHistory 在当前用户的历史记录中全文搜索
+func (h *Handler) searchHistory(c *gin.Context) {
+	var query model.SearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		model.ParamError(c, err)
+		return
+	}
+	if strings.TrimSpace(query.Q) == "" {
+		model.ParamError(c, errors.New("q is required"))
+		return
+	}
+	result, err := h.app.Search.Search(c.Request.Context(), c.GetInt("userID"), query.Q, query.Limit, query.Offset)
+	if err != nil {
+		logger.WithRequest(c.Request).Error("Failed to search history", zap.String("q", query.Q), zap.Error(err))
+		model.Error(c, 2004, "搜索失败")
+		return
+	}
+	model.Success(c, result)
+}
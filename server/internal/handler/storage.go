@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"os"
+)
+
+// serveStorage 提供本地存储中的文件，链接中的签名由上传时生成，只有拿到链接的用户可以访问
+func (h *Handler) serveStorage(c *gin.Context) {
+	c.Header("Access-Control-Allow-Origin", "*")                                    // 允许所有来源
+	c.Header("Access-Control-Allow-Methods", "GET, OPTIONS")                        // 允许的方法
+	c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization") // 允许的请求头
+	if c.Request.Method == "OPTIONS" {
+		c.AbortWithStatus(204)
+		return
+	}
+	storage := h.app.LocalStorage
+	name := c.Param("filename")
+	fullPath, ok := storage.Path(name)
+	if !ok || !storage.Verify(name, c.Query("sig")) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+	if info, err := os.Stat(fullPath); err != nil || info.IsDir() {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	// 链接长期有效，文件名带有时间戳，内容不会改变
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.File(fullPath)
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"feishu2md/server/internal/logger"
+	"feishu2md/server/internal/model"
+	"feishu2md/server/internal/progress"
+	"feishu2md/server/pkg/metrics"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// streamKeepAlive 没有进度时发送注释行的间隔，避免代理因连接空闲而断开
+const streamKeepAlive = 15 * time.Second
+
+// transformStream 与 transformV1 相同的转换，以 Server-Sent Events 推送进度：
+// 转换过程中推送 progress 事件，最后推送 result 或 error 事件后结束
+func (h *Handler) transformStream(c *gin.Context) {
+	log := logger.WithRequest(c.Request)
+	metrics.TotalRequests.WithLabelValues(c.Request.Method, c.FullPath()).Inc()
+
+	// 请求错误在开始推送前按普通 JSON 返回
+	var req model.Req
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Error("Failed to decode request body", logger.WithError(err))
+		model.Error(c, 1002, "解析请求体错误")
+		return
+	}
+	if err := validateRequestFields(&req, h.app.Config.Current().ImgConfig); err != nil {
+		log.Error("Request validation failed", logger.WithError(err))
+		model.Error(c, 2001, "Request validation failed")
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 关闭 nginx 的响应缓冲
+	c.Status(http.StatusOK)
+
+	// 图片并发处理时多个 goroutine 同时上报，写入响应需要加锁；
+	// 处理结束后 finished 为 true，之后迟到的上报不再写入已归还的 gin.Context
+	var (
+		mu       sync.Mutex
+		finished bool
+	)
+	send := func(event string, data interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		if finished {
+			return
+		}
+		c.SSEvent(event, data)
+		c.Writer.Flush()
+	}
+	c.Request = c.Request.WithContext(progress.WithReporter(c.Request.Context(), func(e progress.Event) {
+		send("progress", e)
+	}))
+
+	done := make(chan struct{})
+	defer func() {
+		mu.Lock()
+		finished = true
+		mu.Unlock()
+		close(done)
+	}()
+	go func() {
+		ticker := time.NewTicker(streamKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				mu.Lock()
+				if !finished {
+					c.Writer.WriteString(": keep-alive\n\n")
+					c.Writer.Flush()
+				}
+				mu.Unlock()
+			}
+		}
+	}()
+
+	markdown, tittle, images, err := h.runTransform(c, &req)
+	if err != nil {
+		log.Error("Processing failed", zap.Error(err))
+		resp, ok := err.(*model.ErrorResponse)
+		if !ok {
+			resp = &model.ErrorResponse{Code: 1007, Message: "执行解析失败"}
+		}
+		send("error", gin.H{"code": resp.Code, "message": resp.Message, "images": images})
+		return
+	}
+	send("result", gin.H{
+		"markdown": markdown,
+		"Title":    tittle,
+		"images":   images,
+	})
+	metrics.QPS.WithLabelValues(c.Request.Method, c.FullPath()).Inc()
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"errors"
+	"feishu2md/server/internal/logger"
+	"feishu2md/server/internal/model"
+	"feishu2md/server/internal/service/token"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// refreshTokenRequest 刷新与注销请求，注销时 refresh_token 可选
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// refreshToken 用刷新令牌换取新的访问令牌与刷新令牌，原刷新令牌随即失效
+func (h *Handler) refreshToken(c *gin.Context) {
+	var req refreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+		model.ParamError(c, errors.New("refresh_token is required"))
+		return
+	}
+	pair, err := h.app.Tokens.Refresh(c.Request.Context(), req.RefreshToken)
+	if errors.Is(err, token.ErrInvalidToken) {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid refresh token"})
+		return
+	}
+	if err != nil {
+		logger.WithRequest(c.Request).Error("Failed to refresh token", zap.Error(err))
+		model.Error(c, 1001, "刷新token失败")
+		return
+	}
+	model.Success(c, pair)
+}
+
+// logout 注销当前访问令牌，并作废请求中的刷新令牌
+func (h *Handler) logout(c *gin.Context) {
+	var req refreshTokenRequest
+	// 请求体可以为空
+	_ = c.ShouldBindJSON(&req)
+	claims := c.MustGet("claims").(*token.Claims)
+	err := h.app.Tokens.Revoke(c.Request.Context(), claims, req.RefreshToken)
+	if errors.Is(err, token.ErrInvalidToken) {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid refresh token"})
+		return
+	}
+	if err != nil {
+		logger.WithRequest(c.Request).Error("Failed to revoke token", zap.Error(err))
+		model.Error(c, 1001, "注销失败")
+		return
+	}
+	model.Success(c, nil)
+}
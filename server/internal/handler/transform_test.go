@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"feishu2md/server/internal/config"
+	"feishu2md/server/internal/container"
+	"feishu2md/server/internal/feishu/feishutest"
+	"feishu2md/server/internal/logger"
+	"feishu2md/server/internal/model"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sampleDocURL = "https://sample.feishu.cn/docx/doxcnSampleDocument"
+
+func TestMain(m *testing.M) {
+	logger.L = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// newTestRouter 以 development 配置创建完整的路由，飞书接口指向 srv，数据库、存储与索引位于临时目录，
+// Redis 不可达时缓存与限流使用进程内实现；返回路由与用户 1 的访问令牌
+func newTestRouter(t *testing.T, srv *feishutest.Server) (*gin.Engine, string) {
+	t.Helper()
+	configDir, err := filepath.Abs("../config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataDir := t.TempDir()
+
+	for name, value := range map[string]string{
+		"APP_ENV":                             "development",
+		"FEISHU2MD_CONFIG_DIR":                configDir,
+		"FEISHU2MD_DATABASE_PATH":             filepath.Join(dataDir, "feishu2md.db"),
+		"FEISHU2MD_SEARCH_PATH":               filepath.Join(dataDir, "search.db"),
+		"FEISHU2MD_STORAGE_LOCAL_DIR":         filepath.Join(dataDir, "storage"),
+		"FEISHU2MD_FEISHU_APP_ID":             feishutest.AppID,
+		"FEISHU2MD_FEISHU_APP_SECRET":         feishutest.AppSecret,
+		"FEISHU2MD_FEISHU_OPEN_BASE_URL":      srv.URL,
+		"FEISHU2MD_FEISHU_RATE_LIMIT_BACKEND": "memory",
+		"FEISHU2MD_FEISHU_RETRY_MAX_ATTEMPTS": "1",
+		"FEISHU2MD_REDIS_ADDR":                "127.0.0.1:1",
+		"FEISHU2MD_REDIS_DIAL_TIMEOUT":        "100ms",
+		"FEISHU2MD_LOG_LEVEL":                 "error",
+	} {
+		t.Setenv(name, value)
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := container.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { app.Close() })
+
+	router := gin.New()
+	RegisterRoutes(router, NewHandler(app))
+	pair, err := app.Tokens.Issue(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router, pair.AccessToken
+}
+
+// postTransform 调用 /v1/transform 并解析响应
+func postTransform(t *testing.T, router *gin.Engine, accessToken string, req model.Req) model.Resp {
+	t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/v1/transform", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer "+accessToken)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	var resp model.Resp
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response %s: %v", w.Body, err)
+	}
+	return resp
+}
+
+func TestTransformDocxWithImages(t *testing.T) {
+	srv, err := feishutest.NewServer(feishutest.SampleFixtures())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	router, accessToken := newTestRouter(t, srv)
+
+	resp := postTransform(t, router, accessToken, model.Req{Url: sampleDocURL, WithImageDownload: true})
+	if resp.Code != 0 {
+		t.Fatalf("code = %d, msg = %s", resp.Code, resp.Msg)
+	}
+	var data struct {
+		Markdown string              `json:"markdown"`
+		Images   []model.ImageResult `json:"images"`
+	}
+	raw, _ := json.Marshal(resp.Data)
+	if err := json.Unmarshal(raw, &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Images) != 1 {
+		t.Fatalf("images = %+v, want 1", data.Images)
+	}
+	image := data.Images[0]
+	if image.Token != "boxcnSampleImage" || image.Status != model.ImageCompleted || image.URL == "" {
+		t.Fatalf("image = %+v", image)
+	}
+	if strings.Contains(data.Markdown, "(boxcnSampleImage)") || !strings.Contains(data.Markdown, "("+image.URL+")") {
+		t.Errorf("image token not replaced with %s:\n%s", image.URL, data.Markdown)
+	}
+	if unmatched := srv.Unmatched(); len(unmatched) > 0 {
+		t.Errorf("unmatched requests: %v", unmatched)
+	}
+}
+
+func TestTransformErrorStatus(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status int
+		code   int64
+		want   int
+	}{
+		{"no permission", http.StatusForbidden, 1770032, http.StatusForbidden},
+		{"not found", http.StatusNotFound, 1770002, http.StatusNotFound},
+		{"rate limited", http.StatusTooManyRequests, 99991400, http.StatusTooManyRequests},
+		{"server error", http.StatusInternalServerError, 1500, http.StatusBadGateway},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, err := feishutest.NewServer(feishutest.SampleFixtures())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer srv.Close()
+			body, _ := json.Marshal(map[string]interface{}{"code": tc.code, "msg": tc.name})
+			srv.Add(&feishutest.Fixture{
+				Method: http.MethodGet,
+				Path:   "/open-apis/docx/v1/documents/doxcnSampleDocument",
+				Status: tc.status,
+				Body:   body,
+			})
+			router, accessToken := newTestRouter(t, srv)
+
+			resp := postTransform(t, router, accessToken, model.Req{Url: sampleDocURL})
+			if resp.Code != tc.want {
+				t.Errorf("code = %d (%s), want %d", resp.Code, resp.Msg, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"feishu2md/server/internal/model"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// ValidAdminToken 请求头 X-Admin-Token 是否与 expected 一致，expected 为空时总是 false
+func ValidAdminToken(c *gin.Context, expected string) bool {
+	return expected != "" && subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Admin-Token")), []byte(expected)) == 1
+}
+
+// AdminMiddleware 校验请求头 X-Admin-Token 或具有 admin 权限的 API Key，token 返回当前配置的令牌，
+// 令牌为空时拒绝所有请求
+func AdminMiddleware(token func() string, keys APIKeys) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := token()
+		if expected == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "admin api disabled"})
+			return
+		}
+		if c.GetHeader("X-Admin-Token") == "" {
+			if plain := apiKeyFromRequest(c); plain != "" {
+				if _, ok := authenticateAPIKey(c, keys, plain, model.ScopeAdmin); ok {
+					c.Next()
+				}
+				return
+			}
+		}
+		if !ValidAdminToken(c, expected) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Invalid admin token"})
+			return
+		}
+		c.Next()
+	}
+}
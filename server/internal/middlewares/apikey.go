@@ -0,0 +1,76 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"feishu2md/server/internal/logger"
+	"feishu2md/server/internal/model"
+	"feishu2md/server/internal/service/apikey"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+)
+
+// APIKeys API Key 的校验与计数，由 apikey.Service 实现
+type APIKeys interface {
+	Authenticate(ctx context.Context, key string) (*model.APIKey, error)
+	Allow(ctx context.Context, key *model.APIKey) error
+}
+
+// apiKeyFromRequest 读取请求头 X-API-Key，或以 f2m_ 开头的 Authorization: Bearer
+func apiKeyFromRequest(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	if token := bearerToken(c); strings.HasPrefix(token, apikey.KeyPrefix) {
+		return token
+	}
+	return ""
+}
+
+// AuthMiddleware 接受 JWT 或 API Key，使用 API Key 时要求其具有 scope，并计入 Key 的限流与每日配额
+func AuthMiddleware(tokens Tokens, keys APIKeys, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		plain := apiKeyFromRequest(c)
+		if plain == "" {
+			if authenticateJWT(c, tokens) {
+				c.Next()
+			}
+			return
+		}
+		key, ok := authenticateAPIKey(c, keys, plain, scope)
+		if !ok {
+			return
+		}
+		c.Set("userID", key.UserID)
+		c.Set("apiKey", key)
+		c.Next()
+	}
+}
+
+// authenticateAPIKey 校验 API Key 的权限与用量，失败时中止请求
+func authenticateAPIKey(c *gin.Context, keys APIKeys, plain, scope string) (*model.APIKey, bool) {
+	key, err := keys.Authenticate(c.Request.Context(), plain)
+	if err != nil {
+		if !errors.Is(err, apikey.ErrInvalidKey) {
+			logger.WithRequest(c.Request).Error("Failed to authenticate api key", zap.Error(err))
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, model.Resp{Code: http.StatusUnauthorized, Msg: "Invalid API key"})
+		return nil, false
+	}
+	if !key.HasScope(scope) {
+		c.AbortWithStatusJSON(http.StatusForbidden, model.Resp{Code: http.StatusForbidden, Msg: "API key lacks scope: " + scope})
+		return nil, false
+	}
+	if err := keys.Allow(c.Request.Context(), key); err != nil {
+		var resp *model.ErrorResponse
+		if errors.As(err, &resp) {
+			c.AbortWithStatusJSON(resp.Code, model.Resp{Code: resp.Code, Msg: resp.Message})
+			return nil, false
+		}
+		// 计数失败时放行，Redis 故障不应让所有 Key 不可用
+		logger.WithRequest(c.Request).Error("Failed to check api key quota", zap.Error(err))
+	}
+	return key, true
+}
@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"sync/atomic"
+)
+
+// CORS 跨域中间件，允许的来源可在运行时通过 SetOrigins 更新
+type CORS struct {
+	origins atomic.Pointer[map[string]bool]
+}
+
+// NewCORS 创建跨域中间件
+func NewCORS(origins []string) *CORS {
+	m := &CORS{}
+	m.SetOrigins(origins)
+	return m
+}
+
+// SetOrigins 替换允许的来源，"*" 表示允许全部
+func (m *CORS) SetOrigins(origins []string) {
+	set := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		set[origin] = true
+	}
+	m.origins.Store(&set)
+}
+
+func (m *CORS) allowOrigin(origin string) bool {
+	set := *m.origins.Load()
+	return set["*"] || set[origin]
+}
+
+// Handler 返回 gin 中间件
+func (m *CORS) Handler() gin.HandlerFunc {
+	return cors.New(cors.Config{
+		AllowOriginFunc:  m.allowOrigin,                                                // 允许的来源
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},                     // 允许的请求方法
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "token"}, // 允许的请求头
+		AllowCredentials: true,                                                         // 允许携带凭证
+	})
+}
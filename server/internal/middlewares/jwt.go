@@ -1,57 +1,81 @@
 package middlewares
 
 import (
+	"context"
+	"errors"
+	"feishu2md/server/internal/logger"
 	"feishu2md/server/internal/model"
-	"feishu2md/server/pkg/utils"
+	"feishu2md/server/internal/service/token"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 	"net/http"
 	"strings"
 )
 
+// Tokens 访问令牌的校验，由 token.Service 实现
+type Tokens interface {
+	Validate(ctx context.Context, accessToken string) (*token.Claims, error)
+}
+
 // JWTMiddleware 验证 JWT 的中间件
-func JWTMiddleware() gin.HandlerFunc {
+func JWTMiddleware(tokens Tokens) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var tokenString string
-
-		// 1. 从 header 中查找 "token" 字段
-		tokenString = c.GetHeader("token")
-
-		// 2. 如果没有从 header 查找，再从 Authorization header 查找
-		if tokenString == "" {
-			authHeader := c.GetHeader("Authorization")
-			if authHeader != "" {
-				// Authorization: Bearer <token>
-				parts := strings.Split(authHeader, " ")
-				if len(parts) == 2 && parts[0] == "Bearer" {
-					tokenString = parts[1]
-				}
-			}
+		if !authenticateJWT(c, tokens) {
+			return
 		}
+		// 继续处理请求
+		c.Next()
+	}
+}
 
-		// 3. 如果还没有，从 URL 路由参数查找是否拼接了 token
-		if tokenString == "" {
-			tokenString = c.Param("jwtToken")
-		}
+// authenticateJWT 校验请求中的 JWT 并将用户 ID 与声明写入上下文，失败时中止请求并返回 false
+func authenticateJWT(c *gin.Context, tokens Tokens) bool {
+	var tokenString string
 
-		// 如果 Token 仍然为空，返回错误
-		if tokenString == "" {
-			model.Error(c, 401, "未授权")
-			c.Abort()
-			return
-		}
+	// 1. 从 header 中查找 "token" 字段
+	tokenString = c.GetHeader("token")
 
-		// 验证 Token
-		claims, err := utils.ValidateJWT(tokenString)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid Token", "error": err.Error()})
-			c.Abort()
-			return
+	// 2. 如果没有从 header 查找，再从 Authorization header 查找
+	if tokenString == "" {
+		tokenString = bearerToken(c)
+	}
+
+	// 3. 如果还没有，从 URL 路由参数查找是否拼接了 token
+	if tokenString == "" {
+		tokenString = c.Param("jwtToken")
+	}
+
+	// 如果 Token 仍然为空，返回错误
+	if tokenString == "" {
+		model.Error(c, 401, "未授权")
+		c.Abort()
+		return false
+	}
+
+	// 验证 Token，已注销的 Token 同样视为无效
+	claims, err := tokens.Validate(c.Request.Context(), tokenString)
+	if err != nil {
+		if !errors.Is(err, token.ErrInvalidToken) && !errors.Is(err, token.ErrRevokedToken) {
+			logger.WithRequest(c.Request).Error("Failed to validate token", zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Failed to validate token"})
+			return false
 		}
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid Token", "error": err.Error()})
+		c.Abort()
+		return false
+	}
 
-		// 将用户 ID 存储到上下文中，后续处理可以直接使用
-		c.Set("userID", claims.UserID)
+	// 将用户 ID 存储到上下文中，后续处理可以直接使用；注销时需要 claims
+	c.Set("userID", claims.UserID)
+	c.Set("claims", claims)
+	return true
+}
 
-		// 继续处理请求
-		c.Next()
+// bearerToken 读取 Authorization: Bearer <token>
+func bearerToken(c *gin.Context) string {
+	parts := strings.Split(c.GetHeader("Authorization"), " ")
+	if len(parts) == 2 && parts[0] == "Bearer" {
+		return parts[1]
 	}
+	return ""
 }
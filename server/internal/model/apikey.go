@@ -0,0 +1,58 @@
+package model
+
+import "time"
+
+// API Key 的权限范围
+const (
+	// ScopeTransform 调用 /v1/transform 转换文档
+	ScopeTransform = "transform"
+	// ScopeHistory 查询、搜索与删除历史记录
+	ScopeHistory = "history"
+	// ScopeAdmin 调用 /admin 接口，创建时需要提供管理令牌
+	ScopeAdmin = "admin"
+)
+
+// Scopes 全部权限范围
+var Scopes = []string{ScopeTransform, ScopeHistory, ScopeAdmin}
+
+// APIKey 用户创建的 API Key，数据库中只保存哈希，明文只在创建时返回一次
+type APIKey struct {
+	ID     int      `json:"id"`
+	UserID int      `json:"user_id"`
+	Name   string   `json:"name"`
+	Prefix string   `json:"prefix"` // 明文的前几位，便于用户辨认
+	Hash   string   `json:"-"`
+	Scopes []string `json:"scopes"`
+	// RateLimit 每分钟请求数上限，0 表示不限制
+	RateLimit int `json:"rate_limit"`
+	// DailyQuota 每天请求数上限，0 表示不限制
+	DailyQuota int        `json:"daily_quota"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// HasScope 是否具有指定权限
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAPIKeyRequest 创建 API Key 的请求，RateLimit 与 DailyQuota 为空时使用配置中的默认值，
+// 不携带管理令牌时只能设置为 1 到默认值之间
+type CreateAPIKeyRequest struct {
+	Name       string   `json:"name" binding:"required,max=64"`
+	Scopes     []string `json:"scopes" binding:"required,min=1"`
+	RateLimit  *int     `json:"rate_limit"`
+	DailyQuota *int     `json:"daily_quota"`
+}
+
+// CreatedAPIKey 创建结果，Key 为明文，只返回这一次
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -1,11 +1,15 @@
 package model
 
+import "time"
+
 // TokenRequest 接收前端请求结构
 type TokenRequest struct {
 	Code         string `json:"code" binding:"required"`
 	RedirectURI  string `json:"redirect_uri"`
 	CodeVerifier string `json:"code_verifier"`
 	Scope        string `json:"scope"`
+	// Domain 发起授权的租户域名，如 larksuite.com，为空时为 feishu.cn
+	Domain string `json:"domain"`
 }
 
 // FeishuTokenResponse 飞书API响应结构
@@ -15,12 +19,43 @@ type FeishuTokenResponse struct {
 	TokenType        string `json:"token_type"`
 	ExpiresIn        int    `json:"expires_in"`
 	RefreshToken     string `json:"refresh_token"`
-	RefreshExpiresIn int    `json:"refresh_expires_in"`
+	RefreshExpiresIn int    `json:"refresh_token_expires_in"`
 	Scope            string `json:"scope"`
 	Err              string `json:"error"`
 	ErrorDescription string `json:"error_description"`
 }
 
+// FeishuUserInfo 飞书用户信息接口返回的登录用户
+type FeishuUserInfo struct {
+	Name      string `json:"name"`
+	EnName    string `json:"en_name"`
+	AvatarURL string `json:"avatar_url"`
+	OpenID    string `json:"open_id"`
+	UnionID   string `json:"union_id"`
+	Email     string `json:"email"`
+	UserID    string `json:"user_id"`
+	TenantKey string `json:"tenant_key"`
+}
+
+// FeishuAccount 与本地用户关联的飞书账号，用户访问令牌只保存在服务端
+type FeishuAccount struct {
+	ID               int        `json:"id"`
+	UserID           int        `json:"user_id"`
+	Domain           string     `json:"domain"`
+	OpenID           string     `json:"open_id"`
+	UnionID          string     `json:"union_id"`
+	TenantKey        string     `json:"tenant_key"`
+	Name             string     `json:"name"`
+	AvatarURL        string     `json:"avatar_url"`
+	Email            string     `json:"email"`
+	AccessToken      string     `json:"-"`
+	RefreshToken     string     `json:"-"`
+	AccessExpiresAt  time.Time  `json:"-"`
+	RefreshExpiresAt *time.Time `json:"-"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
 type FeishuTokenReq struct {
 	GrantType    string `json:"grant_type"`
 	ClientID     string `json:"client_id" binding:"required"`
@@ -11,11 +11,14 @@ type User struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 type Transform struct {
-	ID        int       `json:"id"`
-	UserID    int       `json:"user_id"`
-	Url       string    `json:"url"`
-	Result    string    `json:"result"`
-	Tittle    string    `json:"tittle"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         int    `json:"id"`
+	UserID     int    `json:"user_id"`
+	Url        string `json:"url"`
+	Result     string `json:"result"`
+	Tittle     string `json:"tittle"`
+	RevisionID int64  `json:"revision_id"`
+	// Images 下载图片时每张图片的处理结果
+	Images    []ImageResult `json:"images,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
 }
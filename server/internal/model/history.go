@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// HistoryQuery 历史记录列表查询参数
+type HistoryQuery struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit"`
+	// DocType 文档类型，如 docx、wiki、sheets、base
+	DocType string `form:"doc_type"`
+	// Domain 文档域名，如 feishu.cn 或 xxx.feishu.cn
+	Domain string `form:"domain"`
+	// From/To 创建时间范围，支持 2006-01-02 与 RFC3339
+	From string `form:"from"`
+	To   string `form:"to"`
+	// Q 在标题与内容中搜索
+	Q string `form:"q"`
+}
+
+// TransformSummary 历史记录列表项，不包含 Markdown 内容
+type TransformSummary struct {
+	ID         int       `json:"id"`
+	Url        string    `json:"url"`
+	Tittle     string    `json:"tittle"`
+	DocType    string    `json:"doc_type"`
+	Domain     string    `json:"domain"`
+	RevisionID int64     `json:"revision_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// HistoryPage 一页历史记录，NextCursor 为空表示没有更多数据
+type HistoryPage struct {
+	Items      []TransformSummary `json:"items"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+	HasMore    bool               `json:"has_more"`
+}
@@ -0,0 +1,31 @@
+package model
+
+// 图片的处理状态
+const (
+	ImageCompleted = "completed" // 已下载并上传
+	ImageCached    = "cached"    // 使用缓存中的访问链接
+	ImageFailed    = "failed"
+)
+
+// 图片处理失败的原因
+const (
+	ImageErrRateLimited  = "rate_limited"  // 等待限流额度超时或飞书持续限流
+	ImageErrNoPermission = "no_permission" // 没有图片的访问权限
+	ImageErrNotFound     = "not_found"     // 图片不存在或已删除
+	ImageErrDownload     = "download_failed"
+	ImageErrUpload       = "upload_failed"
+	ImageErrCanceled     = "canceled" // 请求已取消或超时
+)
+
+// ImageResult 一张图片的处理结果
+type ImageResult struct {
+	Token  string `json:"token"`
+	Status string `json:"status"`
+	URL    string `json:"url,omitempty"`
+	// ThumbnailURL 配置了缩略图时缩略图的访问链接
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+	// Error 失败的原因，成功时为空
+	Error string `json:"error,omitempty"`
+	// Attempts 下载请求的发送次数，包含重试；使用缓存时为 0
+	Attempts int `json:"attempts"`
+}
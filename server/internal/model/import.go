@@ -0,0 +1,21 @@
+package model
+
+// ImportRequest Markdown 导入飞书文档请求
+type ImportRequest struct {
+	Markdown string `json:"markdown" binding:"required"`
+	// Url 为已有文档地址时替换其内容，为空时在 FolderToken 指定的文件夹中新建文档
+	Url             string `json:"url"`
+	FolderToken     string `json:"folder_token"`
+	Title           string `json:"title"`
+	Domain          string `json:"domain"` // 新建文档时使用，默认为 feishu.cn
+	UserAccessToken string `json:"user_access_token"`
+}
+
+// ImportResult Markdown 导入结果
+type ImportResult struct {
+	DocumentID string   `json:"document_id"`
+	Url        string   `json:"url"`
+	RevisionID int64    `json:"revision_id"`
+	BlockCount int      `json:"block_count"`
+	Warnings   []string `json:"warnings,omitempty"`
+}
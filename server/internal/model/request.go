@@ -1,12 +1,16 @@
 package model
 
-// Req 定义request的结构体
+import "feishu2md/server/pkg/conf"
+
+// Req 定义request的结构体，用户身份取自令牌，不由请求体提供
 type Req struct {
-	Id                string `json:"id"`
 	Url               string `json:"url"`
 	Collection        string `json:"collection"`
-	AccessKey         string `json:"access_key"`
-	UserAccessToken   string `json:"user_access_token"`
+	UserAccessToken   string `json:"user_access_token"` // 仅在用户未关联飞书账号时使用，已关联时以保存的令牌为准
 	WithImageDownload bool   `json:"with_image_download"`
 	IsFile            bool   `json:"is_file"`
+	// StrictImages 任一图片处理失败时整个转换失败，为空时使用 image.strict 配置
+	StrictImages *bool `json:"strict_images"`
+	// ImageProcess 上传前对图片的处理，为空时使用 image.process 配置
+	ImageProcess *conf.ImageProcessConfig `json:"image_process"`
 }
@@ -0,0 +1,38 @@
+package model
+
+// RevisionDiffRequest 飞书文档版本对比请求
+type RevisionDiffRequest struct {
+	Url             string `json:"url" binding:"required"`
+	UserAccessToken string `json:"user_access_token"`
+	// FromRevision/ToRevision 支持具体版本号、"latest" 以及 "previous"（相对另一端的上一个版本）
+	FromRevision string `json:"from_revision"`
+	ToRevision   string `json:"to_revision"`
+	SaveHistory  bool   `json:"save_history"`
+}
+
+// HistoryDiffRequest 历史导出记录对比请求
+type HistoryDiffRequest struct {
+	FromID int `json:"from_id" binding:"required"`
+	ToID   int `json:"to_id" binding:"required"`
+}
+
+// BlockChange 块级别的变更
+type BlockChange struct {
+	Type      string `json:"type"` // added / removed / modified
+	BlockID   string `json:"block_id"`
+	BlockType int64  `json:"block_type"`
+	Before    string `json:"before,omitempty"`
+	After     string `json:"after,omitempty"`
+}
+
+// RevisionDiffResult 版本对比结果
+type RevisionDiffResult struct {
+	Title        string        `json:"title"`
+	FromRevision int64         `json:"from_revision"`
+	ToRevision   int64         `json:"to_revision"`
+	UnifiedDiff  string        `json:"unified_diff"`
+	Changes      []BlockChange `json:"changes,omitempty"`
+	// FromHistoryID/ToHistoryID 为保存到历史记录后的记录ID
+	FromHistoryID int `json:"from_history_id,omitempty"`
+	ToHistoryID   int `json:"to_history_id,omitempty"`
+}
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// SearchQuery 全文搜索参数
+type SearchQuery struct {
+	// Q 搜索词，空格分隔的词需同时命中，双引号内为短语
+	Q      string `form:"q"`
+	Limit  int    `form:"limit"`
+	Offset int    `form:"offset"`
+}
+
+// SearchHit 一条搜索结果，TitleHighlight 与 Snippet 为 HTML，命中的词用 <mark> 标记
+type SearchHit struct {
+	ID             int       `json:"id"`
+	Url            string    `json:"url"`
+	Tittle         string    `json:"tittle"`
+	TitleHighlight string    `json:"title_highlight"`
+	Snippet        string    `json:"snippet"`
+	Score          float64   `json:"score"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// SearchResult 搜索结果，Total 为命中的总数
+type SearchResult struct {
+	Total int         `json:"total"`
+	Items []SearchHit `json:"items"`
+}
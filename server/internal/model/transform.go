@@ -34,7 +34,8 @@ type SheetContentResult struct {
 }
 
 type DocContentResult struct {
-	Markdown  string   `json:"markdown"`
-	DocTitle  string   `json:"docTitle"`
-	ImgTokens []string `json:"imgTokens"`
+	Markdown   string   `json:"markdown"`
+	DocTitle   string   `json:"docTitle"`
+	ImgTokens  []string `json:"imgTokens"`
+	RevisionID int64    `json:"revisionId"`
 }
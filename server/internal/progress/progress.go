@@ -0,0 +1,54 @@
+// Package progress 通过上下文传递转换进度，流式接口在上下文中放入 Reporter，
+// 转换流程中的各个阶段调用 Report 上报；上下文中没有 Reporter 时上报不产生任何开销。
+package progress
+
+import "context"
+
+// 转换的阶段
+const (
+	// StageDocument 已获取文档信息
+	StageDocument = "document"
+	// StageBlocks 已获取一页文档块，Done 为累计获取的块数
+	StageBlocks = "blocks"
+	// StageTable 已展开一个嵌入的电子表格或多维表格
+	StageTable = "table"
+	// StageImage 一张图片处理完成或失败
+	StageImage = "image"
+	// StageSaved 已保存历史记录
+	StageSaved = "saved"
+)
+
+// Event 一次进度事件，各阶段只填写与其相关的字段
+type Event struct {
+	Stage string `json:"stage"`
+	// Title 文档标题，StageDocument 时填写
+	Title string `json:"title,omitempty"`
+	// Page 块列表的页码，从 1 开始
+	Page int `json:"page,omitempty"`
+	// Done 与 Total 当前阶段已完成与总共的数量
+	Done  int `json:"done,omitempty"`
+	Total int `json:"total,omitempty"`
+	// Token 图片 token，Status 与 Error 为图片的处理状态与失败原因，取值同 model.ImageResult
+	Token  string `json:"token,omitempty"`
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// ID 保存的历史记录 ID
+	ID int `json:"id,omitempty"`
+}
+
+// Reporter 接收进度事件，可能被多个 goroutine 同时调用
+type Reporter func(Event)
+
+type reporterKey struct{}
+
+// WithReporter 返回带有 reporter 的上下文
+func WithReporter(ctx context.Context, reporter Reporter) context.Context {
+	return context.WithValue(ctx, reporterKey{}, reporter)
+}
+
+// Report 将事件交给 ctx 中的 Reporter，没有 Reporter 时忽略
+func Report(ctx context.Context, event Event) {
+	if reporter, ok := ctx.Value(reporterKey{}).(Reporter); ok {
+		reporter(event)
+	}
+}
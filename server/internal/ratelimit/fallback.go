@@ -0,0 +1,37 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Health 共享存储的可用状态，由 cache.Failover 实现
+type Health interface {
+	// Healthy 共享存储是否可用
+	Healthy() bool
+	// Fail 报告一次操作的错误，返回是否为连接类错误
+	Fail(err error) bool
+}
+
+// fallback 共享存储不可用时改用本实例内的限流
+type fallback struct {
+	primary   Limiter
+	secondary Limiter
+	health    Health
+}
+
+// WithFallback health 报告不可用或 primary 出现连接类错误时使用 secondary 限流
+func WithFallback(primary, secondary Limiter, health Health) Limiter {
+	return &fallback{primary: primary, secondary: secondary, health: health}
+}
+
+func (f *fallback) Reserve(ctx context.Context, key string, limit Limit, maxWait time.Duration) (time.Duration, error) {
+	if f.health.Healthy() {
+		wait, err := f.primary.Reserve(ctx, key, limit, maxWait)
+		if errors.Is(err, ErrWaitExceeded) || !f.health.Fail(err) {
+			return wait, err
+		}
+	}
+	return f.secondary.Reserve(ctx, key, limit, maxWait)
+}
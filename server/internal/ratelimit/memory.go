@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory 进程内的限流，只适用于单实例部署
+type Memory struct {
+	mu sync.Mutex
+	// tats 每个桶的理论到达时间（GCRA），早于当前时间时桶是满的
+	tats map[string]time.Time
+}
+
+// NewMemory 创建进程内限流
+func NewMemory() *Memory {
+	return &Memory{tats: make(map[string]time.Time)}
+}
+
+func (m *Memory) Reserve(ctx context.Context, key string, limit Limit, maxWait time.Duration) (time.Duration, error) {
+	interval := limit.interval()
+	tolerance := interval * time.Duration(max(limit.Burst, 1))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	tat := m.tats[key]
+	if tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(interval)
+	wait := max(next.Sub(now)-tolerance, 0)
+	if wait > maxWait {
+		return 0, ErrWaitExceeded
+	}
+	m.tats[key] = next
+	// 清理已经回满的桶，避免 key 无限增长
+	if len(m.tats) > 1024 {
+		for k, t := range m.tats {
+			if t.Before(now) {
+				delete(m.tats, k)
+			}
+		}
+	}
+	return wait, nil
+}
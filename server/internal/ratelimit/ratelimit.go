@@ -0,0 +1,86 @@
+// Package ratelimit 按名称区分的令牌桶限流，多实例部署时通过 Redis 共享令牌，单实例可使用进程内实现。
+//
+// 限流采用预约方式：Reserve 立即取走一个令牌并返回需要等待的时间，调用方等待后直接发起请求，
+// 无需轮询；预约的令牌在等待期间被取消时不会归还。
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrWaitExceeded 等待令牌的时间会超过上下文的截止时间或 WithMaxWait 指定的上限，未预约令牌
+var ErrWaitExceeded = errors.New("ratelimit: wait exceeds limit")
+
+// Limit 令牌桶参数，每秒补充 Rate 个令牌，最多累积 Burst 个
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// interval 补充一个令牌的时间
+func (l Limit) interval() time.Duration {
+	return time.Duration(float64(time.Second) / l.Rate)
+}
+
+// Unlimited 是否不限流
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0
+}
+
+// Limiter 令牌桶的存储
+type Limiter interface {
+	// Reserve 从 key 对应的桶中预约一个令牌，返回需要等待的时间；需要等待超过 maxWait 时不预约，返回 ErrWaitExceeded
+	Reserve(ctx context.Context, key string, limit Limit, maxWait time.Duration) (time.Duration, error)
+}
+
+// noDeadline 没有截止时间时允许的最长等待
+const noDeadline = time.Hour
+
+type maxWaitKey struct{}
+
+// WithMaxWait 限制 ctx 下每次 Wait 的最长等待时间
+func WithMaxWait(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, maxWaitKey{}, d)
+}
+
+// maxWait 取上下文截止时间与 WithMaxWait 中较短的一个
+func maxWait(ctx context.Context) time.Duration {
+	wait := noDeadline
+	if d, ok := ctx.Value(maxWaitKey{}).(time.Duration); ok && d > 0 && d < wait {
+		wait = d
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if d := time.Until(deadline); d < wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// Wait 预约 key 的一个令牌并等待到可用，limit 不限流时立即返回
+func Wait(ctx context.Context, limiter Limiter, key string, limit Limit) error {
+	if limit.Unlimited() {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	wait, err := limiter.Reserve(ctx, key, limit, maxWait(ctx))
+	if err != nil {
+		return fmt.Errorf("ratelimit %s: %w", key, err)
+	}
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// reserveScript GCRA 预约：KEYS[1] 保存理论到达时间（毫秒），使用 Redis 服务器时间避免各实例时钟偏差。
+// ARGV 为补充一个令牌的整数毫秒数、桶容量与最长等待毫秒数，返回等待毫秒数，超过最长等待时返回 -1
+var reserveScript = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local interval = tonumber(ARGV[1])
+local tolerance = interval * tonumber(ARGV[2])
+local max_wait = tonumber(ARGV[3])
+local tat = tonumber(redis.call("GET", KEYS[1]) or "0")
+if tat < now then
+    tat = now
+end
+local new_tat = tat + interval
+local wait = new_tat - now - tolerance
+if wait < 0 then
+    wait = 0
+end
+if wait > max_wait then
+    return -1
+end
+redis.call("SET", KEYS[1], new_tat, "PX", new_tat - now + 1000)
+return wait
+`)
+
+// keyPrefix 限流在 Redis 中的 key 前缀
+const keyPrefix = "ratelimit:"
+
+// Redis 多实例共享的限流
+type Redis struct {
+	client redis.Scripter
+	prefix string
+}
+
+// NewRedis 使用 Redis 客户端创建限流，prefix 加在所有 key 之前用于区分命名空间
+func NewRedis(client redis.Scripter, prefix string) *Redis {
+	return &Redis{client: client, prefix: prefix + keyPrefix}
+}
+
+func (r *Redis) Reserve(ctx context.Context, key string, limit Limit, maxWait time.Duration) (time.Duration, error) {
+	interval := max(limit.interval().Milliseconds(), 1)
+	wait, err := reserveScript.Run(ctx, r.client, []string{r.prefix + key},
+		interval, max(limit.Burst, 1), maxWait.Milliseconds()).Int64()
+	if err != nil {
+		return 0, err
+	}
+	if wait < 0 {
+		return 0, ErrWaitExceeded
+	}
+	return time.Duration(wait) * time.Millisecond, nil
+}
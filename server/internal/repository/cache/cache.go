@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound key 不存在
+var ErrNotFound = errors.New("cache: key not found")
+
+// urlTTL 图片访问链接的缓存时间
+const urlTTL = 30 * 24 * time.Hour
+
+// Cache 键值缓存，由 RedisCache、Memory 与 Failover 实现
+type Cache interface {
+	// GetURL 读取图片的访问链接，不存在时返回 ErrNotFound
+	GetURL(ctx context.Context, imgToken string) (string, error)
+	SetURL(ctx context.Context, imgToken string, url string) error
+	SetEX(ctx context.Context, key, value string, ttl time.Duration) error
+	GetEX(ctx context.Context, key string) (value string, ttl time.Duration, ok bool, err error)
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	Take(ctx context.Context, key string) (value string, ok bool, err error)
+	Exists(ctx context.Context, key string) (bool, error)
+	IncrWindow(ctx context.Context, key string, window time.Duration) (int64, error)
+	Close() error
+}
+
+var (
+	_ Cache = (*RedisCache)(nil)
+	_ Cache = (*Memory)(nil)
+	_ Cache = (*Failover)(nil)
+)
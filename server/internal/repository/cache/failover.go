@@ -0,0 +1,187 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"feishu2md/server/internal/logger"
+	"feishu2md/server/pkg/metrics"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"sync/atomic"
+	"time"
+)
+
+// defaultProbeInterval Redis 不可用时检查恢复的间隔
+const defaultProbeInterval = 5 * time.Second
+
+// Failover 优先使用 Redis，Redis 连接出错时降级为进程内缓存，并在后台检查 Redis 是否恢复。
+//
+// 降级期间写入的值只保存在本实例中：恢复后 Redis 中读不到的 key 仍会从进程内缓存读取，直到过期；
+// 多实例间的限流计数、互斥锁与令牌拒绝列表在降级期间按实例各自计算
+type Failover struct {
+	redis   *RedisCache
+	memory  *Memory
+	healthy atomic.Bool
+	probe   time.Duration
+}
+
+// NewFailover 创建降级缓存并检查一次 Redis，不可用时以降级状态启动
+func NewFailover(ctx context.Context, redis *RedisCache, memory *Memory, probe time.Duration) *Failover {
+	if probe <= 0 {
+		probe = defaultProbeInterval
+	}
+	f := &Failover{redis: redis, memory: memory, probe: probe}
+	if err := redis.Ping(ctx); err != nil {
+		logger.L.Warn("Redis unavailable, using in-memory cache until it recovers", zap.Error(err))
+		metrics.RedisUp.Set(0)
+	} else {
+		f.healthy.Store(true)
+		metrics.RedisUp.Set(1)
+	}
+	return f
+}
+
+// Healthy Redis 是否可用
+func (f *Failover) Healthy() bool {
+	return f.healthy.Load()
+}
+
+// Redis 返回 Redis 缓存，供限流等需要执行脚本的组件使用
+func (f *Failover) Redis() *RedisCache {
+	return f.redis
+}
+
+// Fail 报告一次 Redis 操作的错误，连接类错误会切换到进程内缓存，返回是否为连接类错误
+func (f *Failover) Fail(err error) bool {
+	if !unavailable(err) {
+		return false
+	}
+	if f.healthy.CompareAndSwap(true, false) {
+		logger.L.Error("Redis unavailable, falling back to in-memory cache", zap.Error(err))
+		metrics.RedisUp.Set(0)
+	}
+	return true
+}
+
+// unavailable 是否为连接类错误；key 不存在、Redis 返回的错误与调用方取消不影响可用状态
+func unavailable(err error) bool {
+	if err == nil || errors.Is(err, redis.Nil) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var redisErr redis.Error
+	return !errors.As(err, &redisErr)
+}
+
+// Start 在后台定期检查不可用的 Redis，恢复后切换回 Redis，ctx 结束后停止
+func (f *Failover) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(f.probe)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if f.Healthy() {
+				continue
+			}
+			probeCtx, cancel := context.WithTimeout(ctx, f.probe)
+			err := f.redis.Ping(probeCtx)
+			cancel()
+			if err == nil && f.healthy.CompareAndSwap(false, true) {
+				logger.L.Info("Redis recovered, switching back from in-memory cache")
+				metrics.RedisUp.Set(1)
+			}
+		}
+	}()
+}
+
+func (f *Failover) GetURL(ctx context.Context, imgToken string) (string, error) {
+	if f.Healthy() {
+		url, err := f.redis.GetURL(ctx, imgToken)
+		if !errors.Is(err, ErrNotFound) && !f.Fail(err) {
+			return url, err
+		}
+	}
+	return f.memory.GetURL(ctx, imgToken)
+}
+
+func (f *Failover) SetURL(ctx context.Context, imgToken string, url string) error {
+	if f.Healthy() {
+		if err := f.redis.SetURL(ctx, imgToken, url); !f.Fail(err) {
+			return err
+		}
+	}
+	return f.memory.SetURL(ctx, imgToken, url)
+}
+
+// SetEX 写入带过期时间的值
+func (f *Failover) SetEX(ctx context.Context, key, value string, ttl time.Duration) error {
+	if f.Healthy() {
+		if err := f.redis.SetEX(ctx, key, value, ttl); !f.Fail(err) {
+			return err
+		}
+	}
+	return f.memory.SetEX(ctx, key, value, ttl)
+}
+
+// GetEX 读取值及其剩余有效期
+func (f *Failover) GetEX(ctx context.Context, key string) (string, time.Duration, bool, error) {
+	if f.Healthy() {
+		value, ttl, ok, err := f.redis.GetEX(ctx, key)
+		if (ok || err != nil) && !f.Fail(err) {
+			return value, ttl, ok, err
+		}
+	}
+	return f.memory.GetEX(ctx, key)
+}
+
+// SetNX key 不存在时写入带过期时间的值，返回是否写入
+func (f *Failover) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	if f.Healthy() {
+		ok, err := f.redis.SetNX(ctx, key, value, ttl)
+		if !f.Fail(err) {
+			return ok, err
+		}
+	}
+	return f.memory.SetNX(ctx, key, value, ttl)
+}
+
+// Take 读取并删除 key
+func (f *Failover) Take(ctx context.Context, key string) (string, bool, error) {
+	if f.Healthy() {
+		value, ok, err := f.redis.Take(ctx, key)
+		if (ok || err != nil) && !f.Fail(err) {
+			return value, ok, err
+		}
+	}
+	return f.memory.Take(ctx, key)
+}
+
+// Exists key 是否存在
+func (f *Failover) Exists(ctx context.Context, key string) (bool, error) {
+	if f.Healthy() {
+		ok, err := f.redis.Exists(ctx, key)
+		if (ok || err != nil) && !f.Fail(err) {
+			return ok, err
+		}
+	}
+	return f.memory.Exists(ctx, key)
+}
+
+// IncrWindow 固定窗口计数
+func (f *Failover) IncrWindow(ctx context.Context, key string, window time.Duration) (int64, error) {
+	if f.Healthy() {
+		n, err := f.redis.IncrWindow(ctx, key, window)
+		if !f.Fail(err) {
+			return n, err
+		}
+	}
+	return f.memory.IncrWindow(ctx, key, window)
+}
+
+// Close 关闭 Redis 连接
+func (f *Failover) Close() error {
+	return f.redis.Close()
+}
@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// sweepEvery 写入达到该次数时清理一次过期的 key
+const sweepEvery = 1024
+
+type memoryItem struct {
+	value   string
+	expires time.Time // 零值表示不过期
+}
+
+func (i memoryItem) expired(now time.Time) bool {
+	return !i.expires.IsZero() && !now.Before(i.expires)
+}
+
+// Memory 进程内缓存，用于单实例部署或 Redis 不可用时的降级
+type Memory struct {
+	mu     sync.Mutex
+	items  map[string]memoryItem
+	writes int
+}
+
+// NewMemory 创建进程内缓存
+func NewMemory() *Memory {
+	return &Memory{items: make(map[string]memoryItem)}
+}
+
+// get 返回未过期的值，调用方需持有锁
+func (m *Memory) get(key string, now time.Time) (memoryItem, bool) {
+	item, ok := m.items[key]
+	if ok && item.expired(now) {
+		delete(m.items, key)
+		return memoryItem{}, false
+	}
+	return item, ok
+}
+
+// set 写入值，调用方需持有锁
+func (m *Memory) set(key, value string, ttl time.Duration, now time.Time) {
+	item := memoryItem{value: value}
+	if ttl > 0 {
+		item.expires = now.Add(ttl)
+	}
+	m.items[key] = item
+	if m.writes++; m.writes >= sweepEvery {
+		m.writes = 0
+		for k, v := range m.items {
+			if v.expired(now) {
+				delete(m.items, k)
+			}
+		}
+	}
+}
+
+func (m *Memory) GetURL(ctx context.Context, imgToken string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.get(imgToken, time.Now())
+	if !ok {
+		return "", ErrNotFound
+	}
+	return item.value, nil
+}
+
+func (m *Memory) SetURL(ctx context.Context, imgToken string, url string) error {
+	return m.SetEX(ctx, imgToken, url, urlTTL)
+}
+
+// SetEX 写入带过期时间的值
+func (m *Memory) SetEX(ctx context.Context, key, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.set(key, value, ttl, time.Now())
+	return nil
+}
+
+// GetEX 读取值及其剩余有效期，未设置过期时间时 ttl 为 -1，与 Redis 的 PTTL 一致
+func (m *Memory) GetEX(ctx context.Context, key string) (string, time.Duration, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	item, ok := m.get(key, now)
+	if !ok {
+		return "", 0, false, nil
+	}
+	if item.expires.IsZero() {
+		return item.value, -1, true, nil
+	}
+	return item.value, item.expires.Sub(now), true, nil
+}
+
+// SetNX key 不存在时写入带过期时间的值，返回是否写入
+func (m *Memory) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if _, ok := m.get(key, now); ok {
+		return false, nil
+	}
+	m.set(key, value, ttl, now)
+	return true, nil
+}
+
+// Take 读取并删除 key
+func (m *Memory) Take(ctx context.Context, key string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.get(key, time.Now())
+	if ok {
+		delete(m.items, key)
+	}
+	return item.value, ok, nil
+}
+
+// Exists key 是否存在
+func (m *Memory) Exists(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.get(key, time.Now())
+	return ok, nil
+}
+
+// IncrWindow 固定窗口计数，返回加一后的计数，key 在 window 后过期
+func (m *Memory) IncrWindow(ctx context.Context, key string, window time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	item, ok := m.get(key, now)
+	if !ok {
+		m.set(key, "1", window, now)
+		return 1, nil
+	}
+	n, _ := strconv.ParseInt(item.value, 10, 64)
+	n++
+	item.value = strconv.FormatInt(n, 10)
+	m.items[key] = item
+	return n, nil
+}
+
+// Close 进程内缓存无需关闭
+func (m *Memory) Close() error {
+	return nil
+}
@@ -2,154 +2,203 @@ package cache
 
 import (
 	"context"
-	"encoding/base64"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"feishu2md/server/pkg/conf"
 	"fmt"
 	"log"
+	"os"
 	"time"
 )
 import "github.com/redis/go-redis/v9"
 
-type RedisConfig struct {
-	Cluster    string
-	Host       string
-	Port       string
-	Password   string
-	MaxRetries int
+// RedisCache 基于 Redis 的缓存，多个实例共享；支持单节点、哨兵与集群模式，所有 key 加上配置的前缀
+type RedisCache struct {
+	client redis.UniversalClient
+	config conf.RedisConfig
+	prefix string
 }
 
-type RedisCache struct {
-	client *redis.Client
-	config RedisConfig
-}
-
-// InitRedisClient 根据集群环境初始化客户端
-func InitRedisClient(cluster string) *RedisCache {
-	//baseStaging := "cWhaUG1POWhKMERlRFhkaEYwX2NDWlZsX3Y3d3k0Tjk="
-	//baseProduction := "MEk3NGpUR1k0WF9xY1VtOVVnZnl6OVVBQ09iT3pKQV8="
-	baseStaging := "local"
-	baseProduction := "test"
-	decodePassword := func(encoded string) string {
-		decoded, err := base64.StdEncoding.DecodeString(encoded)
+// DialRedis 按配置创建客户端，不检查连接；连接断开后客户端会在之后的命令中自动重连
+func DialRedis(config conf.RedisConfig) (*RedisCache, error) {
+	opts, err := redisOptions(config)
+	if err != nil {
+		return nil, err
+	}
+	var client redis.UniversalClient
+	switch config.Mode {
+	case "sentinel":
+		client = redis.NewFailoverClient(opts.Failover())
+	case "cluster":
+		client = redis.NewClusterClient(opts.Cluster())
+	default:
+		client = redis.NewClient(opts.Simple())
+	}
+	return &RedisCache{client: client, config: config, prefix: config.KeyPrefix}, nil
+}
+
+// redisOptions 将配置转换为 go-redis 的选项
+func redisOptions(config conf.RedisConfig) (*redis.UniversalOptions, error) {
+	opts := &redis.UniversalOptions{
+		Addrs:            config.Addrs,
+		MasterName:       config.MasterName,
+		Username:         config.Username,
+		Password:         config.Password,
+		SentinelUsername: config.SentinelUsername,
+		SentinelPassword: config.SentinelPassword,
+		DB:               config.DB,
+		MaxRetries:       config.MaxRetries,
+		DialTimeout:      config.DialTimeout,
+		ReadTimeout:      config.ReadTimeout,
+		WriteTimeout:     config.WriteTimeout,
+		PoolSize:         config.PoolSize,
+		MinIdleConns:     config.MinIdleConns,
+		PoolTimeout:      config.PoolTimeout,
+		ConnMaxIdleTime:  config.ConnMaxIdleTime,
+	}
+	if config.Mode == "" || config.Mode == "standalone" {
+		opts.Addrs = []string{config.Addr}
+	}
+	if config.TLS.Enabled {
+		tlsConfig, err := redisTLS(config.TLS)
 		if err != nil {
-			return ""
+			return nil, err
 		}
-		return string(decoded)
+		opts.TLSConfig = tlsConfig
 	}
+	return opts, nil
+}
 
-	var config RedisConfig
-	switch cluster {
-	case "staging":
-		config = RedisConfig{
-			Host:     "ares.test.common.cache.srv",
-			Port:     "22127",
-			Password: decodePassword(baseStaging),
-			Cluster:  cluster,
+// redisTLS 读取证书文件生成 TLS 配置
+func redisTLS(config conf.RedisTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("redis tls ca_file: %w", err)
 		}
-	case "production":
-		config = RedisConfig{
-			Host:     "ares.ai.ai.cache.srv",
-			Port:     "5123",
-			Password: decodePassword(baseProduction),
-			Cluster:  cluster,
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("redis tls ca_file: no certificate found in %s", config.CAFile)
 		}
-	default:
-		config = RedisConfig{
-			Host:    "127.0.0.1",
-			Port:    "6379",
-			Cluster: "local",
+	}
+	if config.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("redis tls cert_file: %w", err)
 		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
+	return tlsConfig, nil
+}
 
-	client := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%s", config.Host, config.Port),
-		Password: config.Password,
-		DB:       0,
-	})
-
+// NewRedisCache 按配置初始化客户端并检查连接
+func NewRedisCache(config conf.RedisConfig) (*RedisCache, error) {
+	c, err := DialRedis(config)
+	if err != nil {
+		return nil, err
+	}
 	// 连接健康检查
-	if err := client.Ping(context.Background()).Err(); err != nil {
-		panic(fmt.Sprintf("Redis connection failed: %v", err))
-	} else {
-		log.Println("Connected to Redis successfully")
+	if err := c.Ping(context.Background()); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("Redis connection failed: %v", err)
 	}
+	log.Println("Connected to Redis successfully")
+	return c, nil
+}
 
-	return &RedisCache{
-		client: client,
-		config: config,
-	}
+// Ping 检查 Redis 是否可用
+func (c *RedisCache) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
+
+// Client 返回底层客户端，供限流等需要执行脚本的组件使用，其 key 需自行加上 KeyPrefix
+func (c *RedisCache) Client() redis.UniversalClient {
+	return c.client
+}
+
+// KeyPrefix 配置的 key 前缀
+func (c *RedisCache) KeyPrefix() string {
+	return c.prefix
+}
+
+// key 加上前缀后的 key
+func (c *RedisCache) key(key string) string {
+	return c.prefix + key
+}
+
+// Close 关闭 Redis 连接
+func (c *RedisCache) Close() error {
+	return c.client.Close()
 }
 
 func (c *RedisCache) GetURL(ctx context.Context, imgToken string) (string, error) {
 	// 从Redis获取预签名URL
-	return c.client.Get(ctx, imgToken).Result()
+	url, err := c.client.Get(ctx, c.key(imgToken)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrNotFound
+	}
+	return url, err
 }
 
 func (c *RedisCache) SetURL(ctx context.Context, imgToken string, url string) error {
 	// 设置Redis缓存
-	return c.client.Set(ctx, imgToken, url, time.Hour*24*30).Err()
-}
-
-// TokenBucketRateLimit 令牌桶限流器
-func (c *RedisCache) TokenBucketRateLimit(ctx context.Context, key string, limit int, refillRate int) (bool, error) {
-	luaScript := `
-        local tokens = tonumber(redis.call("GET", KEYS[1]) or "0")
-        local timestamp = tonumber(redis.call("GET", KEYS[2]) or "0")
-        local now = tonumber(ARGV[1])
-        local refill_rate = tonumber(ARGV[2])
-        local capacity = tonumber(ARGV[3])
-
-        -- 计算需要补充的令牌
-        local new_tokens = math.min(capacity, tokens + math.floor((now - timestamp) * refill_rate))
-        if new_tokens < 1 then
-            return 0
-        else
-            redis.call("SET", KEYS[1], new_tokens - 1)
-            redis.call("SET", KEYS[2], now)
-            return 1
-        end
-    `
-	now := time.Now().Unix()
-	log.Println("程序运行到 TokenBucketRateLimit 函数中")
-	result, err := c.client.Eval(ctx, luaScript, []string{key + ":tokens", key + ":timestamp"}, now, refillRate, limit).Result()
-	log.Println("程序运行到 TokenBucketRateLimit 函数后")
-	log.Printf("result:%v", result)
-	if err != nil {
-		return false, err
-	}
-	return result == int64(1), nil
+	return c.client.Set(ctx, c.key(imgToken), url, urlTTL).Err()
 }
 
-// RetryDownloadRateLimit 全局限流和重试
-func (c *RedisCache) RetryDownloadRateLimit(ctx context.Context, imgToken string, maxRetries int, maxWaitTime time.Duration) bool {
-	retryCount := 0
-	totalWaitTime := time.Duration(0)
-
-	for retryCount < maxRetries {
-		log.Println("程序运行到 RetryDownloadRateLimit 函数中")
+// SetEX 写入带过期时间的值
+func (c *RedisCache) SetEX(ctx context.Context, key, value string, ttl time.Duration) error {
+	return c.client.Set(ctx, c.key(key), value, ttl).Err()
+}
 
-		allowed, err := c.TokenBucketRateLimit(ctx, "global_download_rate", 5, 5)
-		if err != nil {
-			log.Printf("Error checking rate limit for image %s: %v", imgToken, err)
-			return false
-		}
-		log.Println("程序运行到 RetryDownloadRateLimit 函数后")
-		if allowed {
-			return true // 限流成功，返回 true
-		}
+// GetEX 读取值及其剩余有效期，key 不存在时 ok 为 false
+func (c *RedisCache) GetEX(ctx context.Context, key string) (value string, ttl time.Duration, ok bool, err error) {
+	pipe := c.client.Pipeline()
+	get := pipe.Get(ctx, c.key(key))
+	pttl := pipe.PTTL(ctx, c.key(key))
+	if _, err = pipe.Exec(ctx); errors.Is(err, redis.Nil) {
+		return "", 0, false, nil
+	} else if err != nil {
+		return "", 0, false, err
+	}
+	return get.Val(), pttl.Val(), true, nil
+}
 
-		// 限流失败，等待一段时间再试
-		log.Printf("Rate limit exceeded for image %s, retrying...", imgToken)
-		retryCount++
-		waitTime := time.Second * time.Duration(retryCount) // 每次重试等待增加
-		totalWaitTime += waitTime
-		if totalWaitTime > maxWaitTime {
-			log.Printf("Total wait time exceeded %v, skipping image %s", maxWaitTime, imgToken)
-			return false // 等待时间超过最大值，返回 false
-		}
+// SetNX key 不存在时写入带过期时间的值，返回是否写入，用于多实例间的互斥
+func (c *RedisCache) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return c.client.SetNX(ctx, c.key(key), value, ttl).Result()
+}
 
-		time.Sleep(waitTime)
+// Take 读取并删除 key，key 不存在时 ok 为 false，用于只能使用一次的令牌
+func (c *RedisCache) Take(ctx context.Context, key string) (value string, ok bool, err error) {
+	value, err = c.client.GetDel(ctx, c.key(key)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
 	}
+	return value, err == nil, err
+}
+
+// Exists key 是否存在
+func (c *RedisCache) Exists(ctx context.Context, key string) (bool, error) {
+	n, err := c.client.Exists(ctx, c.key(key)).Result()
+	return n > 0, err
+}
 
-	log.Printf("Rate limit exceeded for image %s after %d retries, skipping", imgToken, maxRetries)
-	return false // 超过最大重试次数，返回 false
+// incrWindowScript 计数加一，窗口内第一次计数时设置过期时间
+var incrWindowScript = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if n == 1 then
+    redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// IncrWindow 固定窗口计数，返回加一后的计数，key 在 window 后过期
+func (c *RedisCache) IncrWindow(ctx context.Context, key string, window time.Duration) (int64, error) {
+	return incrWindowScript.Run(ctx, c.client, []string{c.key(key)}, window.Milliseconds()).Int64()
 }
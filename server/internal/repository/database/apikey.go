@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"feishu2md/server/internal/model"
+	"strings"
+	"time"
+)
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, rate_limit, daily_quota, last_used_at, revoked_at, created_at`
+
+// APIKeyRepository api_key 表
+type APIKeyRepository struct {
+	db *DB
+}
+
+// NewAPIKeyRepository 创建 APIKeyRepository 实例
+func NewAPIKeyRepository(db *DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+func (r *APIKeyRepository) table() string {
+	return r.db.Dialect.Quote("api_key")
+}
+
+func scanAPIKey(row scanner) (*model.APIKey, error) {
+	var (
+		key                 model.APIKey
+		scopes              string
+		lastUsed, revokedAt sql.NullTime
+	)
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.Hash, &scopes, &key.RateLimit, &key.DailyQuota,
+		&lastUsed, &revokedAt, &key.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	if lastUsed.Valid {
+		key.LastUsedAt = &lastUsed.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
+
+// Create 插入记录并回填 ID
+func (r *APIKeyRepository) Create(ctx context.Context, key *model.APIKey) error {
+	id, err := r.db.Insert(ctx, `INSERT INTO `+r.table()+` (user_id, name, prefix, key_hash, scopes, rate_limit, daily_quota, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		key.UserID, key.Name, key.Prefix, key.Hash, strings.Join(key.Scopes, ","), key.RateLimit, key.DailyQuota, key.CreatedAt)
+	if err != nil {
+		return err
+	}
+	key.ID = int(id)
+	return nil
+}
+
+// FindActiveByHash 根据哈希查找未吊销的 Key，不存在时返回 ErrNotFound
+func (r *APIKeyRepository) FindActiveByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	return scanAPIKey(r.db.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM `+r.table()+` WHERE key_hash = ? AND revoked_at IS NULL`, hash))
+}
+
+// CountActiveByUser 统计用户未吊销的 Key 数量
+func (r *APIKeyRepository) CountActiveByUser(ctx context.Context, userID int) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+r.table()+` WHERE user_id = ? AND revoked_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
+
+// ListByUser 按创建顺序倒序返回用户的全部 Key，包括已吊销的
+func (r *APIKeyRepository) ListByUser(ctx context.Context, userID int) ([]model.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM `+r.table()+` WHERE user_id = ? ORDER BY id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []model.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke 吊销用户的一个 Key，Key 不存在、不属于该用户或已吊销时返回 ErrNotFound
+func (r *APIKeyRepository) Revoke(ctx context.Context, userID, id int, at time.Time) error {
+	result, err := r.db.ExecContext(ctx, `UPDATE `+r.table()+` SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL`, at, id, userID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+// Touch 记录 Key 的最近使用时间
+func (r *APIKeyRepository) Touch(ctx context.Context, id int, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE `+r.table()+` SET last_used_at = ? WHERE id = ?`, at, id)
+	return err
+}
@@ -1,23 +1,147 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"feishu2md/server/pkg/conf"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql" // MySQL driver
+	_ "github.com/lib/pq"              // PostgreSQL driver
+	_ "github.com/mattn/go-sqlite3"    // SQLite driver
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 )
 
-// InitializeDB 连接数据库并返回数据库实例
+// Dialect SQL 方言，屏蔽占位符、标识符引用与自增主键的差异
+type Dialect string
+
+const (
+	MySQL    Dialect = "mysql"
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// driverName database/sql 驱动名称
+func (d Dialect) driverName() string {
+	if d == SQLite {
+		return "sqlite3"
+	}
+	return string(d)
+}
+
+// Rebind 将 ? 占位符转换为方言的占位符，postgres 使用 $1、$2…
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Quote 引用表名、列名，user 等在 postgres 中是保留字
+func (d Dialect) Quote(ident string) string {
+	if d == MySQL {
+		return "`" + ident + "`"
+	}
+	return `"` + ident + `"`
+}
+
+// DB 带方言信息的数据库连接，查询语句统一使用 ? 占位符
+type DB struct {
+	*sql.DB
+	Dialect Dialect
+}
+
+// InitializeDB 连接 MySQL 数据库并返回数据库实例
 func InitializeDB(dsn string) (*sql.DB, error) {
+	return connect(MySQL, dsn)
+}
+
+func connect(dialect Dialect, dsn string) (*sql.DB, error) {
 	// 数据源名称（连接字符串）
-	db, err := sql.Open("mysql", dsn)
+	db, err := sql.Open(dialect.driverName(), dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %v", err)
 	}
 
 	// 测试数据库连接
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %v", err)
 	}
 
 	return db, nil
 }
+
+// Open 按配置连接数据库并设置连接池
+func Open(cfg conf.DatabaseConfig) (*DB, error) {
+	dialect := Dialect(cfg.Type)
+	switch dialect {
+	case MySQL, Postgres, SQLite:
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", cfg.Type)
+	}
+	if dialect == SQLite && cfg.Path != ":memory:" {
+		// SQLite 不会自动创建数据库文件所在的目录
+		if err := os.MkdirAll(filepath.Dir(cfg.Path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+	}
+	db, err := connect(dialect, cfg.DSN())
+	if err != nil {
+		return nil, err
+	}
+	if dialect == SQLite {
+		// SQLite 同一时间只允许一个写连接，内存数据库每个连接是独立的库
+		db.SetMaxOpenConns(1)
+	} else if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	return &DB{DB: db, Dialect: dialect}, nil
+}
+
+// ExecContext 执行语句
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.ExecContext(ctx, db.Dialect.Rebind(query), args...)
+}
+
+// QueryContext 执行查询
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.QueryContext(ctx, db.Dialect.Rebind(query), args...)
+}
+
+// QueryRowContext 执行单行查询
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRowContext(ctx, db.Dialect.Rebind(query), args...)
+}
+
+// Insert 执行 INSERT 并返回自增主键，postgres 不支持 LastInsertId，改用 RETURNING
+func (db *DB) Insert(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	if db.Dialect == Postgres {
+		var id int64
+		err := db.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"feishu2md/server/internal/model"
+	"fmt"
+	"time"
+)
+
+const feishuAccountColumns = `id, user_id, domain, open_id, union_id, tenant_key, name, avatar_url, email, access_token, refresh_token, access_expires_at, refresh_expires_at, created_at, updated_at`
+
+// Sealer 加密与解密字段，由 vault.Vault 实现
+type Sealer interface {
+	Seal(plain string) (string, error)
+	Open(sealed string) (string, error)
+}
+
+// FeishuAccountRepository feishu_account 表，访问令牌与刷新令牌加密后保存
+type FeishuAccountRepository struct {
+	db     *DB
+	sealer Sealer
+}
+
+// NewFeishuAccountRepository 创建 FeishuAccountRepository 实例
+func NewFeishuAccountRepository(db *DB, sealer Sealer) *FeishuAccountRepository {
+	return &FeishuAccountRepository{db: db, sealer: sealer}
+}
+
+func (r *FeishuAccountRepository) table() string {
+	return r.db.Dialect.Quote("feishu_account")
+}
+
+func (r *FeishuAccountRepository) scan(row scanner) (*model.FeishuAccount, error) {
+	var (
+		account        model.FeishuAccount
+		refreshExpires sql.NullTime
+	)
+	err := row.Scan(&account.ID, &account.UserID, &account.Domain, &account.OpenID, &account.UnionID, &account.TenantKey, &account.Name,
+		&account.AvatarURL, &account.Email, &account.AccessToken, &account.RefreshToken, &account.AccessExpiresAt,
+		&refreshExpires, &account.CreatedAt, &account.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if refreshExpires.Valid {
+		account.RefreshExpiresAt = &refreshExpires.Time
+	}
+	if account.AccessToken, err = r.sealer.Open(account.AccessToken); err != nil {
+		return nil, fmt.Errorf("decrypt access token of feishu account %d: %w", account.ID, err)
+	}
+	if account.RefreshToken, err = r.sealer.Open(account.RefreshToken); err != nil {
+		return nil, fmt.Errorf("decrypt refresh token of feishu account %d: %w", account.ID, err)
+	}
+	return &account, nil
+}
+
+// seal 返回加密后的访问令牌与刷新令牌
+func (r *FeishuAccountRepository) seal(account *model.FeishuAccount) (accessToken, refreshToken string, err error) {
+	if accessToken, err = r.sealer.Seal(account.AccessToken); err != nil {
+		return "", "", err
+	}
+	if refreshToken, err = r.sealer.Seal(account.RefreshToken); err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+// FindByOpenID 根据租户域名与 open_id 查找飞书账号，不存在时返回 ErrNotFound
+func (r *FeishuAccountRepository) FindByOpenID(ctx context.Context, domain, openID string) (*model.FeishuAccount, error) {
+	return r.scan(r.db.QueryRowContext(ctx, `SELECT `+feishuAccountColumns+` FROM `+r.table()+` WHERE domain = ? AND open_id = ?`, domain, openID))
+}
+
+// FindByUserID 查找用户在租户下关联的飞书账号，不存在时返回 ErrNotFound
+func (r *FeishuAccountRepository) FindByUserID(ctx context.Context, userID int, domain string) (*model.FeishuAccount, error) {
+	return r.scan(r.db.QueryRowContext(ctx, `SELECT `+feishuAccountColumns+` FROM `+r.table()+` WHERE user_id = ? AND domain = ?`, userID, domain))
+}
+
+// ListByUser 返回用户在各租户下关联的飞书账号
+func (r *FeishuAccountRepository) ListByUser(ctx context.Context, userID int) ([]model.FeishuAccount, error) {
+	return r.list(ctx, `SELECT `+feishuAccountColumns+` FROM `+r.table()+` WHERE user_id = ? ORDER BY id`, userID)
+}
+
+func (r *FeishuAccountRepository) list(ctx context.Context, query string, args ...interface{}) ([]model.FeishuAccount, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []model.FeishuAccount{}
+	for rows.Next() {
+		account, err := r.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *account)
+	}
+	return accounts, rows.Err()
+}
+
+// Create 插入记录并回填 ID
+func (r *FeishuAccountRepository) Create(ctx context.Context, account *model.FeishuAccount) error {
+	accessToken, refreshToken, err := r.seal(account)
+	if err != nil {
+		return err
+	}
+	id, err := r.db.Insert(ctx, `INSERT INTO `+r.table()+` (user_id, domain, open_id, union_id, tenant_key, name, avatar_url, email, access_token, refresh_token, access_expires_at, refresh_expires_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		account.UserID, account.Domain, account.OpenID, account.UnionID, account.TenantKey, account.Name, account.AvatarURL, account.Email,
+		accessToken, refreshToken, account.AccessExpiresAt, account.RefreshExpiresAt, account.CreatedAt, account.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	account.ID = int(id)
+	return nil
+}
+
+// Update 按 ID 更新飞书身份、资料与令牌，记录不存在时返回 ErrNotFound
+func (r *FeishuAccountRepository) Update(ctx context.Context, account *model.FeishuAccount) error {
+	accessToken, refreshToken, err := r.seal(account)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx, `UPDATE `+r.table()+` SET open_id = ?, union_id = ?, tenant_key = ?, name = ?, avatar_url = ?, email = ?, access_token = ?, refresh_token = ?, access_expires_at = ?, refresh_expires_at = ?, updated_at = ? WHERE id = ?`,
+		account.OpenID, account.UnionID, account.TenantKey, account.Name, account.AvatarURL, account.Email,
+		accessToken, refreshToken, account.AccessExpiresAt, account.RefreshExpiresAt, account.UpdatedAt, account.ID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+// ListRefreshable 按过期时间返回访问令牌在 before 之前过期、刷新令牌在 now 仍有效的账号
+func (r *FeishuAccountRepository) ListRefreshable(ctx context.Context, before, now time.Time, limit int) ([]model.FeishuAccount, error) {
+	return r.list(ctx, `SELECT `+feishuAccountColumns+` FROM `+r.table()+` WHERE access_expires_at < ? AND refresh_token <> '' AND (refresh_expires_at IS NULL OR refresh_expires_at > ?) ORDER BY access_expires_at LIMIT ?`,
+		before, now, limit)
+}
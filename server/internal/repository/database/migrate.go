@@ -0,0 +1,231 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migration 一个版本的数据库变更，文件位于 migrations/<dialect>/<version>_<name>.sql
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Migrations 读取方言对应的全部迁移，按版本排序
+func Migrations(dialect Dialect) ([]Migration, error) {
+	dir := path.Join("migrations", string(dialect))
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s: %w", dialect, err)
+	}
+	var migrations []Migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || path.Ext(name) != ".sql" {
+			continue
+		}
+		prefix, rest, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid migration file name %s, want <version>_<name>.sql", name)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("duplicate migration version %d: %s, %s", version, other, name)
+		}
+		seen[version] = name
+		data, err := fs.ReadFile(migrationFiles, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: rest, SQL: string(data)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// migrateLock 迁移锁的名称，多个实例同时启动时由持有锁的实例执行迁移，其余实例等待后跳过已执行的版本
+const migrateLock = "feishu2md_schema_migrations"
+
+// migrateLockTimeout 等待 MySQL 迁移锁的最长时间
+const migrateLockTimeout = 10 * time.Minute
+
+// Migrate 执行尚未执行的迁移，已执行的版本记录在 schema_migrations 表中，返回本次执行的迁移。
+// 迁移期间持有数据库锁：MySQL 使用 GET_LOCK，Postgres 使用 pg_advisory_lock，SQLite 在一个写事务中执行全部迁移
+func Migrate(ctx context.Context, db *DB) ([]Migration, error) {
+	migrations, err := Migrations(db.Dialect)
+	if err != nil {
+		return nil, err
+	}
+	// 会话级的锁只在获取它的连接上有效，迁移全程使用同一个连接
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	unlock, err := lock(ctx, conn, db.Dialect)
+	if err != nil {
+		return nil, fmt.Errorf("lock schema_migrations: %w", err)
+	}
+	done, err := migrate(ctx, conn, db.Dialect, migrations)
+	if unlockErr := unlock(err == nil); err == nil {
+		err = unlockErr
+	}
+	if err != nil && db.Dialect == SQLite {
+		// SQLite 的迁移在同一个事务中，失败时全部回滚
+		done = nil
+	}
+	return done, err
+}
+
+// lock 获取迁移锁，返回释放锁的函数，commit 表示迁移是否成功（SQLite 据此提交或回滚事务）
+func lock(ctx context.Context, conn *sql.Conn, dialect Dialect) (func(commit bool) error, error) {
+	// 释放锁时 ctx 可能已取消，仍需释放
+	release := context.Background()
+	switch dialect {
+	case MySQL:
+		var got sql.NullInt64
+		if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, migrateLock, int(migrateLockTimeout.Seconds())).Scan(&got); err != nil {
+			return nil, err
+		}
+		if got.Int64 != 1 {
+			return nil, fmt.Errorf("timed out after %s waiting for lock %s", migrateLockTimeout, migrateLock)
+		}
+		return func(bool) error {
+			_, err := conn.ExecContext(release, `DO RELEASE_LOCK(?)`, migrateLock)
+			return err
+		}, nil
+	case Postgres:
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock(hashtext($1))`, migrateLock); err != nil {
+			return nil, err
+		}
+		return func(bool) error {
+			_, err := conn.ExecContext(release, `SELECT pg_advisory_unlock(hashtext($1))`, migrateLock)
+			return err
+		}, nil
+	default:
+		// IMMEDIATE 事务开始时即获取写锁，其他进程在 busy_timeout 内等待，而不是读到相同的版本后各自迁移
+		if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+			return nil, err
+		}
+		return func(commit bool) error {
+			if commit {
+				_, err := conn.ExecContext(release, `COMMIT`)
+				return err
+			}
+			_, err := conn.ExecContext(release, `ROLLBACK`)
+			return err
+		}, nil
+	}
+}
+
+// migrate 在已持有迁移锁的连接上执行尚未执行的迁移
+func migrate(ctx context.Context, conn *sql.Conn, dialect Dialect, migrations []Migration) ([]Migration, error) {
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version BIGINT NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied_at TIMESTAMP NOT NULL
+)`); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	applied := make(map[int]bool)
+	rows, err := conn.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("query schema_migrations: %w", err)
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+		if err := apply(ctx, conn, dialect, m); err != nil {
+			return done, fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// execer *sql.Conn 与 *sql.Tx 共有的执行方法
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// apply 执行一个迁移并记录版本。MySQL 与 Postgres 每个迁移使用一个事务，MySQL 的 DDL 会隐式提交，迁移语句需可重复执行；
+// SQLite 已处于迁移锁的事务中，直接执行
+func apply(ctx context.Context, conn *sql.Conn, dialect Dialect, m Migration) error {
+	var ex execer = conn
+	var tx *sql.Tx
+	if dialect != SQLite {
+		var err error
+		if tx, err = conn.BeginTx(ctx, nil); err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		ex = tx
+	}
+	for _, stmt := range splitStatements(m.SQL) {
+		if _, err := ex.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := ex.ExecContext(ctx, dialect.Rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+		m.Version, m.Name, time.Now()); err != nil {
+		return err
+	}
+	if tx != nil {
+		return tx.Commit()
+	}
+	return nil
+}
+
+// splitStatements 按行尾的分号拆分语句，忽略 -- 开头的注释行
+func splitStatements(script string) []string {
+	var (
+		stmts   []string
+		current strings.Builder
+	)
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		stmts = append(stmts, rest)
+	}
+	return stmts
+}
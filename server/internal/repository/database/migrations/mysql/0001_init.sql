@@ -0,0 +1,22 @@
+-- 用户与转换历史，已有的库中表可能已存在，此时不会执行建表，
+-- 之后新增的列（如 transform.revision_id）需要单独的迁移补上
+CREATE TABLE IF NOT EXISTS `user` (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    phone VARCHAR(32) NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_user_phone (phone)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `transform` (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    url VARCHAR(1024) NOT NULL,
+    result LONGTEXT NOT NULL,
+    tittle VARCHAR(512) NOT NULL DEFAULT '',
+    revision_id BIGINT NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_transform_user_created (user_id, created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,16 @@
+-- 用户创建的 API Key，只保存哈希
+CREATE TABLE IF NOT EXISTS `api_key` (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) NOT NULL,
+    scopes VARCHAR(255) NOT NULL,
+    rate_limit INT NOT NULL DEFAULT 0,
+    daily_quota INT NOT NULL DEFAULT 0,
+    last_used_at DATETIME NULL,
+    revoked_at DATETIME NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_api_key_hash (key_hash),
+    KEY idx_api_key_user (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
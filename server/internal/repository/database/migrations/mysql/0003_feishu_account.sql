@@ -0,0 +1,22 @@
+-- 通过飞书登录的用户没有手机号
+ALTER TABLE `user` MODIFY phone VARCHAR(32) NULL;
+
+-- 与本地用户关联的飞书账号及其用户访问令牌
+CREATE TABLE IF NOT EXISTS `feishu_account` (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    open_id VARCHAR(64) NOT NULL,
+    union_id VARCHAR(64) NOT NULL DEFAULT '',
+    tenant_key VARCHAR(64) NOT NULL DEFAULT '',
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    avatar_url VARCHAR(1024) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    access_token TEXT NOT NULL,
+    refresh_token TEXT NOT NULL,
+    access_expires_at DATETIME NOT NULL,
+    refresh_expires_at DATETIME NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_feishu_account_open_id (open_id),
+    UNIQUE KEY uk_feishu_account_user (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,8 @@
+-- 飞书账号按租户域名区分，open_id 只在同一应用内唯一，每个用户在每个租户下可关联一个账号；
+-- 合并为一条语句，失败时不会留下部分变更
+ALTER TABLE `feishu_account`
+    ADD COLUMN domain VARCHAR(64) NOT NULL DEFAULT 'feishu.cn' AFTER user_id,
+    DROP INDEX uk_feishu_account_open_id,
+    DROP INDEX uk_feishu_account_user,
+    ADD UNIQUE KEY uk_feishu_account_open_id (domain, open_id),
+    ADD UNIQUE KEY uk_feishu_account_user (user_id, domain);
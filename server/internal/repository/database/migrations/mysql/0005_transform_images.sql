@@ -0,0 +1,3 @@
+-- 转换历史保存每张图片的处理结果（JSON），未下载图片时为 NULL；
+-- 引入迁移前已存在的表在 0006 才补上 revision_id，这里不能依赖该列的位置
+ALTER TABLE `transform` ADD COLUMN images TEXT NULL;
@@ -0,0 +1,10 @@
+-- 引入迁移前已存在的 transform 表没有 revision_id，0001 的 CREATE TABLE IF NOT EXISTS 不会补上该列；
+-- MySQL 不支持 ADD COLUMN IF NOT EXISTS，按 information_schema 判断后再添加
+SET @add_revision_id = IF(
+    (SELECT COUNT(*) FROM information_schema.columns
+     WHERE table_schema = DATABASE() AND table_name = 'transform' AND column_name = 'revision_id') = 0,
+    'ALTER TABLE `transform` ADD COLUMN revision_id BIGINT NOT NULL DEFAULT 0 AFTER tittle',
+    'DO 0');
+PREPARE add_revision_id FROM @add_revision_id;
+EXECUTE add_revision_id;
+DEALLOCATE PREPARE add_revision_id;
@@ -0,0 +1,20 @@
+-- 通过飞书登录的用户没有手机号
+ALTER TABLE "user" ALTER COLUMN phone DROP NOT NULL;
+
+-- 与本地用户关联的飞书账号及其用户访问令牌
+CREATE TABLE IF NOT EXISTS "feishu_account" (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL UNIQUE,
+    open_id VARCHAR(64) NOT NULL UNIQUE,
+    union_id VARCHAR(64) NOT NULL DEFAULT '',
+    tenant_key VARCHAR(64) NOT NULL DEFAULT '',
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    avatar_url VARCHAR(1024) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    access_token TEXT NOT NULL,
+    refresh_token TEXT NOT NULL,
+    access_expires_at TIMESTAMP NOT NULL,
+    refresh_expires_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
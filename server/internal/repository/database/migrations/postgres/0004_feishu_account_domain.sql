@@ -0,0 +1,6 @@
+-- 飞书账号按租户域名区分，open_id 只在同一应用内唯一，每个用户在每个租户下可关联一个账号
+ALTER TABLE "feishu_account" ADD COLUMN IF NOT EXISTS domain VARCHAR(64) NOT NULL DEFAULT 'feishu.cn';
+ALTER TABLE "feishu_account" DROP CONSTRAINT IF EXISTS feishu_account_open_id_key;
+ALTER TABLE "feishu_account" DROP CONSTRAINT IF EXISTS feishu_account_user_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uk_feishu_account_open_id ON "feishu_account" (domain, open_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uk_feishu_account_user ON "feishu_account" (user_id, domain);
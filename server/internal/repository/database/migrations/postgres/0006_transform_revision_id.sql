@@ -0,0 +1,2 @@
+-- 引入迁移前已存在的 transform 表没有 revision_id，0001 的 CREATE TABLE IF NOT EXISTS 不会补上该列
+ALTER TABLE "transform" ADD COLUMN IF NOT EXISTS revision_id BIGINT NOT NULL DEFAULT 0;
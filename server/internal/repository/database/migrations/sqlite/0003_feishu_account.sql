@@ -0,0 +1,30 @@
+-- 通过飞书登录的用户没有手机号，SQLite 不能修改列约束，需要重建 user 表
+CREATE TABLE "user_new" (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    phone VARCHAR(32) NULL UNIQUE,
+    password VARCHAR(255) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO "user_new" (id, phone, password, created_at, updated_at)
+SELECT id, phone, password, created_at, updated_at FROM "user";
+DROP TABLE "user";
+ALTER TABLE "user_new" RENAME TO "user";
+
+-- 与本地用户关联的飞书账号及其用户访问令牌
+CREATE TABLE IF NOT EXISTS "feishu_account" (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL UNIQUE,
+    open_id VARCHAR(64) NOT NULL UNIQUE,
+    union_id VARCHAR(64) NOT NULL DEFAULT '',
+    tenant_key VARCHAR(64) NOT NULL DEFAULT '',
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    avatar_url VARCHAR(1024) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    access_token TEXT NOT NULL,
+    refresh_token TEXT NOT NULL,
+    access_expires_at DATETIME NOT NULL,
+    refresh_expires_at DATETIME NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,25 @@
+-- 飞书账号按租户域名区分，open_id 只在同一应用内唯一，每个用户在每个租户下可关联一个账号；
+-- SQLite 不能删除列上的唯一约束，需要重建表
+CREATE TABLE "feishu_account_new" (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    domain VARCHAR(64) NOT NULL DEFAULT 'feishu.cn',
+    open_id VARCHAR(64) NOT NULL,
+    union_id VARCHAR(64) NOT NULL DEFAULT '',
+    tenant_key VARCHAR(64) NOT NULL DEFAULT '',
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    avatar_url VARCHAR(1024) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    access_token TEXT NOT NULL,
+    refresh_token TEXT NOT NULL,
+    access_expires_at DATETIME NOT NULL,
+    refresh_expires_at DATETIME NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (domain, open_id),
+    UNIQUE (user_id, domain)
+);
+INSERT INTO "feishu_account_new" (id, user_id, open_id, union_id, tenant_key, name, avatar_url, email, access_token, refresh_token, access_expires_at, refresh_expires_at, created_at, updated_at)
+SELECT id, user_id, open_id, union_id, tenant_key, name, avatar_url, email, access_token, refresh_token, access_expires_at, refresh_expires_at, created_at, updated_at FROM "feishu_account";
+DROP TABLE "feishu_account";
+ALTER TABLE "feishu_account_new" RENAME TO "feishu_account";
@@ -0,0 +1,2 @@
+-- 转换历史保存每张图片的处理结果（JSON），未下载图片时为 NULL
+ALTER TABLE "transform" ADD COLUMN images TEXT NULL;
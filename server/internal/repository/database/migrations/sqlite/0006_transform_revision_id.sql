@@ -0,0 +1 @@
+-- SQLite 的库都由 0001 创建，transform 表已包含 revision_id，保留该版本与其他方言一致
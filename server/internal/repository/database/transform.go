@@ -0,0 +1,232 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"feishu2md/server/internal/model"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const transformColumns = `id, user_id, url, result, tittle, revision_id, images, created_at, updated_at`
+
+// TransformRepository transform 表
+type TransformRepository struct {
+	db *DB
+}
+
+// NewTransformRepository 创建 TransformRepository 实例
+func NewTransformRepository(db *DB) *TransformRepository {
+	return &TransformRepository{db: db}
+}
+
+func (r *TransformRepository) table() string {
+	return r.db.Dialect.Quote("transform")
+}
+
+// scanner 兼容 *sql.Row 与 *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTransform(row scanner) (*model.Transform, error) {
+	var (
+		t      model.Transform
+		images sql.NullString
+	)
+	err := row.Scan(&t.ID, &t.UserID, &t.Url, &t.Result, &t.Tittle, &t.RevisionID, &images, &t.CreatedAt, &t.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if images.String != "" {
+		if err := json.Unmarshal([]byte(images.String), &t.Images); err != nil {
+			return nil, fmt.Errorf("transform %d images: %w", t.ID, err)
+		}
+	}
+	return &t, nil
+}
+
+// encodeImages 图片处理结果保存为 JSON，没有图片时为 NULL
+func encodeImages(images []model.ImageResult) (interface{}, error) {
+	if len(images) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(images)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Create 插入记录并回填 ID
+func (r *TransformRepository) Create(ctx context.Context, t *model.Transform) error {
+	images, err := encodeImages(t.Images)
+	if err != nil {
+		return err
+	}
+	id, err := r.db.Insert(ctx, `INSERT INTO `+r.table()+` (user_id, url, result, tittle, revision_id, images, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		t.UserID, t.Url, t.Result, t.Tittle, t.RevisionID, images, t.CreatedAt, t.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	t.ID = int(id)
+	return nil
+}
+
+// Get 根据 ID 查找记录，不存在时返回 ErrNotFound
+func (r *TransformRepository) Get(ctx context.Context, id int) (*model.Transform, error) {
+	return scanTransform(r.db.QueryRowContext(ctx, `SELECT `+transformColumns+` FROM `+r.table()+` WHERE id = ?`, id))
+}
+
+// FindByRevision 查找用户最近一次导出的某个文档版本，不存在时返回 ErrNotFound
+func (r *TransformRepository) FindByRevision(ctx context.Context, userID int, url string, revisionID int64) (*model.Transform, error) {
+	return scanTransform(r.db.QueryRowContext(ctx, `SELECT `+transformColumns+` FROM `+r.table()+` WHERE user_id = ? AND url = ? AND revision_id = ? ORDER BY id DESC LIMIT 1`,
+		userID, url, revisionID))
+}
+
+// Update 更新链接、内容与标题
+func (r *TransformRepository) Update(ctx context.Context, t *model.Transform) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE `+r.table()+` SET url = ?, result = ?, tittle = ?, updated_at = ? WHERE id = ?`,
+		t.Url, t.Result, t.Tittle, t.UpdatedAt, t.ID)
+	return err
+}
+
+// Delete 删除记录，记录不存在时返回 ErrNotFound
+func (r *TransformRepository) Delete(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM `+r.table()+` WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+// ListByUser 按创建时间倒序返回用户的全部记录
+func (r *TransformRepository) ListByUser(ctx context.Context, userID int) ([]model.Transform, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+transformColumns+` FROM `+r.table()+` WHERE user_id = ? ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transforms []model.Transform
+	for rows.Next() {
+		t, err := scanTransform(rows)
+		if err != nil {
+			return nil, err
+		}
+		transforms = append(transforms, *t)
+	}
+	return transforms, rows.Err()
+}
+
+// ListAfter 按 ID 升序返回 ID 大于 afterID 的至多 limit 条记录，用于分批遍历全部记录
+func (r *TransformRepository) ListAfter(ctx context.Context, afterID, limit int) ([]model.Transform, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+transformColumns+` FROM `+r.table()+` WHERE id > ? ORDER BY id LIMIT ?`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transforms []model.Transform
+	for rows.Next() {
+		t, err := scanTransform(rows)
+		if err != nil {
+			return nil, err
+		}
+		transforms = append(transforms, *t)
+	}
+	return transforms, rows.Err()
+}
+
+// checkAffected 没有影响任何行时返回 ErrNotFound
+func checkAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// TransformFilter 历史记录列表的筛选条件，零值表示不筛选
+type TransformFilter struct {
+	UserID int
+	// BeforeID 只返回 ID 小于该值的记录，用于游标分页
+	BeforeID int
+	DocType  string
+	Domain   string
+	From, To time.Time
+	Query    string
+	Limit    int
+}
+
+// likeEscape LIKE 模式使用的转义字符，三种数据库都支持显式指定 ESCAPE
+const likeEscape = "!"
+
+var likeReplacer = strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_")
+
+// List 按 ID 倒序返回满足条件的记录摘要，不读取 Markdown 内容
+func (r *TransformRepository) List(ctx context.Context, filter TransformFilter) ([]model.TransformSummary, error) {
+	var (
+		where = []string{"user_id = ?"}
+		args  = []interface{}{filter.UserID}
+	)
+	like := func(column string, pattern string) string {
+		args = append(args, pattern)
+		return "LOWER(" + column + ") LIKE ? ESCAPE '" + likeEscape + "'"
+	}
+	if filter.BeforeID > 0 {
+		where = append(where, "id < ?")
+		args = append(args, filter.BeforeID)
+	}
+	if filter.DocType != "" {
+		where = append(where, like("url", "%/"+likeReplacer.Replace(strings.ToLower(filter.DocType))+"/%"))
+	}
+	if filter.Domain != "" {
+		// 同时匹配完整域名与其子域名
+		domain := likeReplacer.Replace(strings.ToLower(filter.Domain))
+		where = append(where, "("+like("url", "%://"+domain+"/%")+" OR "+like("url", "%."+domain+"/%")+")")
+	}
+	if !filter.From.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, filter.To)
+	}
+	if filter.Query != "" {
+		pattern := "%" + likeReplacer.Replace(strings.ToLower(filter.Query)) + "%"
+		where = append(where, "("+like("tittle", pattern)+" OR "+like("result", pattern)+")")
+	}
+	query := `SELECT id, url, tittle, revision_id, created_at, updated_at FROM ` + r.table() +
+		` WHERE ` + strings.Join(where, " AND ") + ` ORDER BY id DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ` + strconv.Itoa(filter.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []model.TransformSummary{}
+	for rows.Next() {
+		var item model.TransformSummary
+		if err := rows.Scan(&item.ID, &item.Url, &item.Tittle, &item.RevisionID, &item.CreatedAt, &item.UpdatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"feishu2md/server/internal/model"
+)
+
+// ErrNotFound 记录不存在
+var ErrNotFound = errors.New("record not found")
+
+// UserRepository user 表
+type UserRepository struct {
+	db *DB
+}
+
+// NewUserRepository 创建 UserRepository 实例
+func NewUserRepository(db *DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+func (r *UserRepository) table() string {
+	return r.db.Dialect.Quote("user")
+}
+
+// CountByPhone 统计手机号对应的用户数
+func (r *UserRepository) CountByPhone(ctx context.Context, phone string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+r.table()+` WHERE phone = ?`, phone).Scan(&count)
+	return count, err
+}
+
+// Create 插入用户并回填 ID，通过飞书登录的用户没有手机号，写入 NULL 以免违反唯一约束
+func (r *UserRepository) Create(ctx context.Context, user *model.User) error {
+	phone := sql.NullString{String: user.Phone, Valid: user.Phone != ""}
+	id, err := r.db.Insert(ctx, `INSERT INTO `+r.table()+` (phone, password, created_at, updated_at) VALUES (?, ?, ?, ?)`,
+		phone, user.Password, user.CreatedAt, user.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	user.ID = int(id)
+	return nil
+}
+
+// FindByPhone 根据手机号查找用户，不存在时返回 ErrNotFound
+func (r *UserRepository) FindByPhone(ctx context.Context, phone string) (*model.User, error) {
+	var user model.User
+	err := r.db.QueryRowContext(ctx, `SELECT id, phone, password, created_at, updated_at FROM `+r.table()+` WHERE phone = ?`, phone).
+		Scan(&user.ID, &user.Phone, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindByID 根据 ID 查找用户，不存在时返回 ErrNotFound
+func (r *UserRepository) FindByID(ctx context.Context, id int) (*model.User, error) {
+	var user model.User
+	err := r.db.QueryRowContext(ctx, `SELECT id, COALESCE(phone, ''), password, created_at, updated_at FROM `+r.table()+` WHERE id = ?`, id).
+		Scan(&user.ID, &user.Phone, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -2,6 +2,9 @@ package storage
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -13,10 +16,11 @@ import (
 type LocalStorage struct {
 	BaseDir     string // 存储根目录
 	httpBaseURL string // 访问基础URL（示例）
+	urlSecret   []byte // 访问链接的签名密钥
 }
 
-// NewLocalStorage 创建本地存储实例
-func NewLocalStorage(baseDir string) (*LocalStorage, error) {
+// NewLocalStorage 创建本地存储实例，返回的访问链接带有用 urlSecret 生成的签名
+func NewLocalStorage(baseDir, urlSecret string) (*LocalStorage, error) {
 	fmt.Println("创建本地存储实例！！！！")
 	// 自动创建存储目录
 	if err := os.MkdirAll(baseDir, 0755); err != nil {
@@ -26,6 +30,7 @@ func NewLocalStorage(baseDir string) (*LocalStorage, error) {
 	return &LocalStorage{
 		BaseDir:     baseDir,
 		httpBaseURL: "http://localhost:8080/storage", // 假设本地服务映射
+		urlSecret:   []byte(urlSecret),
 	}, nil
 }
 
@@ -40,15 +45,42 @@ func (s *LocalStorage) Upload(ctx context.Context, filename string, content []by
 	)
 
 	// 构建完整存储路径
-	fullPath := filepath.Join(s.BaseDir, newFilename)
+	fullPath, ok := s.Path(newFilename)
+	if !ok {
+		return "", fmt.Errorf("invalid file name: %s", filename)
+	}
 	fmt.Println("Attempting to write file to:", fullPath) // 输出日志检查路径
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
 	// 写入文件
 	if err := ioutil.WriteFile(fullPath, content, 0644); err != nil {
 		return "", fmt.Errorf("failed to write file: %w", err)
 	}
 
-	// 返回访问URL
-	return fmt.Sprintf("%s/%s", s.httpBaseURL, newFilename), nil
+	// 返回带签名的访问URL
+	return fmt.Sprintf("%s/%s?sig=%s", s.httpBaseURL, newFilename, s.Sign(newFilename)), nil
+}
+
+// Path 返回对象在磁盘上的路径，名称试图跳出存储根目录时 ok 为 false
+func (s *LocalStorage) Path(name string) (fullPath string, ok bool) {
+	name = path.Clean("/" + name)
+	if name == "/" {
+		return "", false
+	}
+	return filepath.Join(s.BaseDir, filepath.FromSlash(name)), true
+}
+
+// Sign 生成对象的访问签名，签名只与对象名称相关，写入 Markdown 的链接长期有效
+func (s *LocalStorage) Sign(name string) string {
+	mac := hmac.New(sha256.New, s.urlSecret)
+	mac.Write([]byte(path.Clean("/" + name)))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// Verify 校验对象的访问签名
+func (s *LocalStorage) Verify(name, sig string) bool {
+	return len(s.urlSecret) > 0 && hmac.Equal([]byte(s.Sign(name)), []byte(sig))
 }
 
 func (s *LocalStorage) Type() StorageType {
@@ -14,7 +14,7 @@ const (
 	StorageTypeFDS   StorageType = "fds"   // 远程FDS存储
 )
 
-// ObjectStorage 对象存储接口
+// ObjectStorage 对象存储接口，对象名称以 users/<用户 ID>/ 开头，按用户隔离
 type ObjectStorage interface {
 	Upload(ctx context.Context, filename string, content []byte) (string, error)
 	Type() StorageType
@@ -24,7 +24,7 @@ type ObjectStorage interface {
 func InitStorageClient(cfg conf.StorageConfig) (ObjectStorage, error) {
 	switch cfg.Type {
 	case string(StorageTypeLocal):
-		return NewLocalStorage(cfg.LocalDir)
+		return NewLocalStorage(cfg.LocalDir, cfg.URLSecret)
 	case string(StorageTypeFDS):
 		return NewFDSStorage(cfg.Endpoint, cfg.Bucket)
 	default:
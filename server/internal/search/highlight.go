@@ -0,0 +1,93 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	markOpen  = "<mark>"
+	markClose = "</mark>"
+)
+
+// interval 命中位置，[start, end) 为 rune 下标
+type interval struct{ start, end int }
+
+// matches 查找 terms 在 text 中的全部命中位置（忽略大小写），重叠的位置会合并
+func matches(text []rune, terms []string) []interval {
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+	var found []interval
+	for _, term := range terms {
+		t := []rune(strings.ToLower(term))
+		if len(t) == 0 {
+			continue
+		}
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) == string(t) {
+				found = append(found, interval{i, i + len(t)})
+			}
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].start < found[j].start })
+	var merged []interval
+	for _, m := range found {
+		if n := len(merged); n > 0 && m.start <= merged[n-1].end {
+			if m.end > merged[n-1].end {
+				merged[n-1].end = m.end
+			}
+			continue
+		}
+		merged = append(merged, m)
+	}
+	return merged
+}
+
+// Highlight 截取第一个命中位置附近至多 size 个字符，HTML 转义后用 <mark> 标记命中的词；
+// size 为 0 时返回全文
+func Highlight(text string, terms []string, size int) string {
+	runes := []rune(text)
+	found := matches(runes, terms)
+
+	start, end := 0, len(runes)
+	if size > 0 && len(runes) > size {
+		if len(found) > 0 {
+			// 命中位置前保留约四分之一的上下文
+			start = found[0].start - size/4
+		}
+		if start < 0 {
+			start = 0
+		}
+		end = start + size
+		if end > len(runes) {
+			end = len(runes)
+			start = end - size
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, m := range found {
+		if m.end <= start || m.start >= end {
+			continue
+		}
+		s, e := max(m.start, start), min(m.end, end)
+		b.WriteString(html.EscapeString(string(runes[pos:s])))
+		b.WriteString(markOpen)
+		b.WriteString(html.EscapeString(string(runes[s:e])))
+		b.WriteString(markClose)
+		pos = e
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:end])))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
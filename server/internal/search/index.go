@@ -0,0 +1,236 @@
+// Package search 为导出的历史记录建立全文索引，索引保存在独立的 SQLite 文件中，
+// 与业务数据库的类型无关，可随时通过 cmd/searchindex 从历史记录重建。
+package search
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"feishu2md/server/internal/model"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"os"
+	"path/filepath"
+)
+
+// driverName 注册了 fts_rank 函数的 SQLite 驱动
+const driverName = "sqlite3_search"
+
+const (
+	// titleWeight/contentWeight 标题命中的权重高于正文
+	titleWeight   = 2.0
+	contentWeight = 1.0
+
+	// snippetSize 正文摘要的字符数，标题不截断
+	snippetSize = 120
+
+	defaultLimit = 20
+	maxLimit     = 50
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS documents (
+	id INTEGER PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	url TEXT NOT NULL,
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_documents_user ON documents (user_id);
+CREATE VIRTUAL TABLE IF NOT EXISTS documents_fts USING fts4 (title, content, tokenize=simple);
+`
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("fts_rank", rank, true)
+		},
+	})
+}
+
+// rank 根据 matchinfo(documents_fts, 'pcx') 计算相关度：每个词在本文档中的命中次数
+// 除以在全部文档中的命中次数，按列加权求和，越罕见的词权重越高
+func rank(info []byte) float64 {
+	if len(info) < 8 {
+		return 0
+	}
+	value := func(i int) float64 {
+		return float64(binary.NativeEndian.Uint32(info[i*4:]))
+	}
+	phrases, columns := int(value(0)), int(value(1))
+	if len(info) < (2+phrases*columns*3)*4 {
+		return 0
+	}
+	weights := []float64{titleWeight, contentWeight}
+	var score float64
+	for p := 0; p < phrases; p++ {
+		for col := 0; col < columns && col < len(weights); col++ {
+			base := 2 + (p*columns+col)*3
+			if hitsAll := value(base + 1); hitsAll > 0 {
+				score += weights[col] * value(base) / hitsAll
+			}
+		}
+	}
+	return score
+}
+
+// Index 全文索引，documents 保存原文用于生成摘要，documents_fts 保存分词结果
+type Index struct {
+	db *sql.DB
+}
+
+// Open 打开或创建索引文件
+func Open(path string) (*Index, error) {
+	if path != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create search index directory: %w", err)
+		}
+	}
+	db, err := sql.Open(driverName, "file:"+path+"?_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open search index: %w", err)
+	}
+	// 索引由服务与重建命令共同写入，单连接避免同一进程内的锁冲突
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create search index: %w", err)
+	}
+	return &Index{db: db}, nil
+}
+
+// Close 关闭索引文件
+func (i *Index) Close() error {
+	return i.db.Close()
+}
+
+// Index 写入或替换一条历史记录的索引
+func (i *Index) Index(ctx context.Context, t *model.Transform) error {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := put(ctx, tx, t); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func put(ctx context.Context, tx *sql.Tx, t *model.Transform) error {
+	if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO documents (id, user_id, url, title, content, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		t.ID, t.UserID, t.Url, t.Tittle, t.Result, t.CreatedAt); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM documents_fts WHERE docid = ?`, t.ID); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `INSERT INTO documents_fts (docid, title, content) VALUES (?, ?, ?)`,
+		t.ID, Tokenize(t.Tittle), Tokenize(t.Result))
+	return err
+}
+
+// Delete 删除一条历史记录的索引，记录未被索引时不报错
+func (i *Index) Delete(ctx context.Context, id int) error {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM documents WHERE id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM documents_fts WHERE docid = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Source 重建索引时读取的历史记录，按 ID 升序返回 ID 大于 afterID 的至多 limit 条记录
+type Source func(ctx context.Context, afterID, limit int) ([]model.Transform, error)
+
+// rebuildBatch 重建时每个事务写入的记录数
+const rebuildBatch = 200
+
+// Rebuild 清空索引后重新写入全部历史记录，返回写入的记录数
+func (i *Index) Rebuild(ctx context.Context, source Source) (int, error) {
+	if _, err := i.db.ExecContext(ctx, `DELETE FROM documents; DELETE FROM documents_fts;`); err != nil {
+		return 0, err
+	}
+	total, afterID := 0, 0
+	for {
+		batch, err := source(ctx, afterID, rebuildBatch)
+		if err != nil {
+			return total, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		tx, err := i.db.BeginTx(ctx, nil)
+		if err != nil {
+			return total, err
+		}
+		for n := range batch {
+			if err := put(ctx, tx, &batch[n]); err != nil {
+				tx.Rollback()
+				return total, fmt.Errorf("index transform %d: %w", batch[n].ID, err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return total, err
+		}
+		total += len(batch)
+		afterID = batch[len(batch)-1].ID
+	}
+	// 合并 FTS 段，减小索引体积
+	_, err := i.db.ExecContext(ctx, `INSERT INTO documents_fts (documents_fts) VALUES ('optimize')`)
+	return total, err
+}
+
+// Search 在用户自己的历史记录中搜索，按相关度排序，相关度相同时较新的记录在前；
+// limit 默认 20，最大 50
+func (i *Index) Search(ctx context.Context, userID int, q string, limit, offset int) (*model.SearchResult, error) {
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	query := ParseQuery(q)
+	result := &model.SearchResult{Items: []model.SearchHit{}}
+	if query.Match == "" {
+		return result, nil
+	}
+
+	const from = ` FROM documents_fts JOIN documents d ON d.id = documents_fts.docid WHERE documents_fts MATCH ? AND d.user_id = ?`
+	if err := i.db.QueryRowContext(ctx, `SELECT COUNT(*)`+from, query.Match, userID).Scan(&result.Total); err != nil {
+		return nil, err
+	}
+	if result.Total == 0 {
+		return result, nil
+	}
+
+	rows, err := i.db.QueryContext(ctx, `SELECT d.id, d.url, d.title, d.content, d.created_at, fts_rank(matchinfo(documents_fts, 'pcx')) AS score`+
+		from+` ORDER BY score DESC, d.id DESC LIMIT ? OFFSET ?`, query.Match, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			hit     model.SearchHit
+			content string
+		)
+		if err := rows.Scan(&hit.ID, &hit.Url, &hit.Tittle, &content, &hit.CreatedAt, &hit.Score); err != nil {
+			return nil, err
+		}
+		hit.TitleHighlight = Highlight(hit.Tittle, query.Terms, 0)
+		hit.Snippet = Highlight(content, query.Terms, snippetSize)
+		result.Items = append(result.Items, hit)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,142 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// isCJK 是否为中日韩文字，这些文字之间没有空格，按二元组切分
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// segment 文本中的一段连续字符，cjk 为 true 时是中日韩文字，否则是字母数字组成的单词
+type segment struct {
+	text string
+	cjk  bool
+}
+
+// segments 将文本切分为单词与中日韩文字段，丢弃标点与空白
+func segments(text string) []segment {
+	var (
+		result  []segment
+		current []rune
+		cjk     bool
+	)
+	flush := func() {
+		if len(current) > 0 {
+			result = append(result, segment{text: string(current), cjk: cjk})
+			current = current[:0]
+		}
+	}
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			if !cjk {
+				flush()
+			}
+			cjk = true
+			current = append(current, r)
+		case isWordRune(r):
+			if cjk {
+				flush()
+			}
+			cjk = false
+			current = append(current, unicode.ToLower(r))
+		default:
+			flush()
+		}
+	}
+	flush()
+	return result
+}
+
+// bigrams 将中日韩文字段切分为相邻两字的组合，单字时返回该字
+func bigrams(text string) []string {
+	runes := []rune(text)
+	if len(runes) == 1 {
+		return []string{text}
+	}
+	grams := make([]string, 0, len(runes)-1)
+	for i := 0; i+1 < len(runes); i++ {
+		grams = append(grams, string(runes[i:i+2]))
+	}
+	return grams
+}
+
+// Tokenize 生成写入索引的词序列，单词转为小写，中日韩文字按二元组切分，以空格分隔
+func Tokenize(text string) string {
+	var tokens []string
+	for _, seg := range segments(text) {
+		if seg.cjk {
+			tokens = append(tokens, bigrams(seg.text)...)
+		} else {
+			tokens = append(tokens, seg.text)
+		}
+	}
+	return strings.Join(tokens, " ")
+}
+
+// Query 解析后的查询
+type Query struct {
+	// Terms 需要全部命中的词或短语，用于高亮
+	Terms []string
+	// Match FTS MATCH 表达式
+	Match string
+}
+
+// ParseQuery 解析查询：双引号内为短语，其余按空白拆分，所有词与短语需同时命中。
+// 连续的中文按二元组短语匹配；只有一个汉字时按前缀匹配以该字开头的二元组，
+// 因此单字查询不会命中该字位于一段中文末尾的位置
+func ParseQuery(q string) Query {
+	var (
+		query Query
+		parts []string
+	)
+	add := func(text string) {
+		var tokens []string
+		for _, seg := range segments(text) {
+			if seg.cjk {
+				tokens = append(tokens, bigrams(seg.text)...)
+			} else {
+				tokens = append(tokens, seg.text)
+			}
+			query.Terms = append(query.Terms, seg.text)
+		}
+		switch {
+		case len(tokens) == 0:
+		case len(tokens) == 1 && len([]rune(tokens[0])) == 1 && isCJK([]rune(tokens[0])[0]):
+			parts = append(parts, tokens[0]+"*")
+		case len(tokens) == 1:
+			parts = append(parts, tokens[0])
+		default:
+			parts = append(parts, `"`+strings.Join(tokens, " ")+`"`)
+		}
+	}
+
+	for {
+		start := strings.IndexByte(q, '"')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(q[start+1:], '"')
+		if end < 0 {
+			break
+		}
+		for _, field := range strings.Fields(q[:start]) {
+			add(field)
+		}
+		add(q[start+1 : start+1+end])
+		q = q[start+1+end+1:]
+	}
+	for _, field := range strings.Fields(q) {
+		add(field)
+	}
+	query.Match = strings.Join(parts, " ")
+	return query
+}
@@ -0,0 +1,339 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"feishu2md/server/internal/logger"
+	"feishu2md/server/internal/model"
+	"feishu2md/server/internal/repository/database"
+	"feishu2md/server/internal/service/auth"
+	"feishu2md/server/internal/tenant"
+	"feishu2md/server/pkg/conf"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// refreshBefore 使用时发现访问令牌将在该时长内过期则立即刷新，避免请求过程中令牌过期；
+	// 更早的刷新交给后台任务，减少多个实例同时刷新同一个令牌
+	refreshBefore = time.Minute
+	// refreshBatch 后台任务每轮最多刷新的令牌数
+	refreshBatch = 100
+	// refreshLockKey 后台刷新的互斥锁，多实例部署时同一时间只有一个实例执行
+	refreshLockKey = "feishu:token:refresh"
+)
+
+var (
+	// ErrAuthorize 授权码无效或获取飞书用户信息失败
+	ErrAuthorize = errors.New("feishu authorization failed")
+	// ErrNotLinked 用户没有关联飞书账号
+	ErrNotLinked = errors.New("feishu account not linked")
+	// ErrReauthorize 刷新令牌已过期或失效，需要用户重新授权
+	ErrReauthorize = errors.New("feishu authorization expired")
+)
+
+// Feishu 飞书 OAuth 接口，由 auth.Service 实现
+type Feishu interface {
+	GetAccessToken(req *model.TokenRequest) (*model.FeishuTokenResponse, error)
+	RefreshAccessToken(domain, refreshToken string) (*model.FeishuTokenResponse, error)
+	GetUserInfo(domain, accessToken string) (*model.FeishuUserInfo, error)
+}
+
+// Repository 飞书账号的存储，由 database.FeishuAccountRepository 实现
+type Repository interface {
+	FindByOpenID(ctx context.Context, domain, openID string) (*model.FeishuAccount, error)
+	FindByUserID(ctx context.Context, userID int, domain string) (*model.FeishuAccount, error)
+	ListByUser(ctx context.Context, userID int) ([]model.FeishuAccount, error)
+	Create(ctx context.Context, account *model.FeishuAccount) error
+	Update(ctx context.Context, account *model.FeishuAccount) error
+	ListRefreshable(ctx context.Context, before, now time.Time, limit int) ([]model.FeishuAccount, error)
+}
+
+// Locker 多实例间的互斥锁，由 cache.Failover 实现
+type Locker interface {
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+}
+
+// Users 本地用户的存储，由 database.UserRepository 实现
+type Users interface {
+	Create(ctx context.Context, user *model.User) error
+	FindByID(ctx context.Context, id int) (*model.User, error)
+}
+
+// Service 通过飞书账号登录、关联本地用户，并保存与刷新用户的飞书访问令牌；
+// 每个用户在每个租户（文档域名）下可以关联一个飞书账号
+type Service struct {
+	feishu   Feishu
+	accounts Repository
+	users    Users
+	locker   Locker
+	config   conf.TokenVaultConfig
+
+	// locks 每个账号一把锁，飞书的刷新令牌只能使用一次，同一账号的并发请求只能刷新一次
+	locks sync.Map
+}
+
+// NewAccountService 创建 Service 实例
+func NewAccountService(feishu Feishu, accounts Repository, users Users, locker Locker, config conf.TokenVaultConfig) *Service {
+	return &Service{feishu: feishu, accounts: accounts, users: users, locker: locker, config: config}
+}
+
+// authorize 用授权码换取令牌并获取飞书用户信息，返回尚未保存的飞书账号
+func (s *Service) authorize(req *model.TokenRequest) (*model.FeishuAccount, error) {
+	domain := req.Domain
+	if domain == "" {
+		domain = tenant.DefaultDomain
+	}
+	token, err := s.feishu.GetAccessToken(req)
+	if errors.Is(err, tenant.ErrUnknownDomain) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuthorize, err)
+	}
+	info, err := s.feishu.GetUserInfo(domain, token.AccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuthorize, err)
+	}
+	account := &model.FeishuAccount{Domain: domain}
+	setProfile(account, info)
+	setToken(account, token, time.Now())
+	return account, nil
+}
+
+func setProfile(account *model.FeishuAccount, info *model.FeishuUserInfo) {
+	account.OpenID = info.OpenID
+	account.UnionID = info.UnionID
+	account.TenantKey = info.TenantKey
+	account.Name = info.Name
+	account.AvatarURL = info.AvatarURL
+	account.Email = info.Email
+}
+
+// setToken 记录令牌及其过期时间，飞书未返回刷新令牌有效期时视为不过期，由刷新结果判断
+func setToken(account *model.FeishuAccount, token *model.FeishuTokenResponse, now time.Time) {
+	account.AccessToken = token.AccessToken
+	account.AccessExpiresAt = now.Add(time.Duration(token.ExpiresIn) * time.Second)
+	account.RefreshToken = token.RefreshToken
+	account.RefreshExpiresAt = nil
+	if token.RefreshExpiresIn > 0 {
+		expires := now.Add(time.Duration(token.RefreshExpiresIn) * time.Second)
+		account.RefreshExpiresAt = &expires
+	}
+	account.UpdatedAt = now
+}
+
+// SignIn 用授权码登录：飞书账号已关联时登录关联的用户，否则创建没有手机号与密码的新用户并关联
+func (s *Service) SignIn(ctx context.Context, req *model.TokenRequest) (*model.User, *model.FeishuAccount, error) {
+	authorized, err := s.authorize(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	account, err := s.accounts.FindByOpenID(ctx, authorized.Domain, authorized.OpenID)
+	if err == nil {
+		user, err := s.users.FindByID(ctx, account.UserID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("查询用户失败: %v", err)
+		}
+		if err := s.save(ctx, account, authorized); err != nil {
+			return nil, nil, err
+		}
+		return user, account, nil
+	}
+	if !errors.Is(err, database.ErrNotFound) {
+		return nil, nil, fmt.Errorf("查询飞书账号失败: %v", err)
+	}
+
+	now := authorized.UpdatedAt
+	user := &model.User{CreatedAt: now, UpdatedAt: now}
+	if err := s.users.Create(ctx, user); err != nil {
+		return nil, nil, fmt.Errorf("创建用户失败: %v", err)
+	}
+	authorized.UserID = user.ID
+	authorized.CreatedAt = now
+	if err := s.accounts.Create(ctx, authorized); err != nil {
+		return nil, nil, fmt.Errorf("保存飞书账号失败: %v", err)
+	}
+	return user, authorized, nil
+}
+
+// Link 将授权码对应的飞书账号关联到已登录的用户，用户在该租户下已关联其他飞书账号时替换为新账号
+func (s *Service) Link(ctx context.Context, userID int, req *model.TokenRequest) (*model.FeishuAccount, error) {
+	authorized, err := s.authorize(req)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := s.accounts.FindByOpenID(ctx, authorized.Domain, authorized.OpenID)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return nil, fmt.Errorf("查询飞书账号失败: %v", err)
+	}
+	if owner != nil && owner.UserID != userID {
+		return nil, &model.ErrorResponse{Code: http.StatusConflict, Message: "该飞书账号已关联其他用户"}
+	}
+
+	account, err := s.accounts.FindByUserID(ctx, userID, authorized.Domain)
+	if errors.Is(err, database.ErrNotFound) {
+		authorized.UserID = userID
+		authorized.CreatedAt = authorized.UpdatedAt
+		if err := s.accounts.Create(ctx, authorized); err != nil {
+			return nil, fmt.Errorf("保存飞书账号失败: %v", err)
+		}
+		return authorized, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询飞书账号失败: %v", err)
+	}
+	if err := s.save(ctx, account, authorized); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// save 用新授权的身份、资料与令牌更新已保存的飞书账号
+func (s *Service) save(ctx context.Context, account, authorized *model.FeishuAccount) error {
+	mu := s.lock(account.UserID, account.Domain)
+	mu.Lock()
+	defer mu.Unlock()
+
+	authorized.ID = account.ID
+	authorized.UserID = account.UserID
+	authorized.CreatedAt = account.CreatedAt
+	*account = *authorized
+	if err := s.accounts.Update(ctx, account); err != nil {
+		return fmt.Errorf("保存飞书账号失败: %v", err)
+	}
+	return nil
+}
+
+func (s *Service) lock(userID int, domain string) *sync.Mutex {
+	mu, _ := s.locks.LoadOrStore(strconv.Itoa(userID)+"@"+domain, &sync.Mutex{})
+	return mu.(*sync.Mutex)
+}
+
+// Account 返回用户在租户下关联的飞书账号，未关联时返回 ErrNotLinked
+func (s *Service) Account(ctx context.Context, userID int, domain string) (*model.FeishuAccount, error) {
+	account, err := s.accounts.FindByUserID(ctx, userID, domain)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, ErrNotLinked
+	}
+	return account, err
+}
+
+// Accounts 返回用户在各租户下关联的飞书账号
+func (s *Service) Accounts(ctx context.Context, userID int) ([]model.FeishuAccount, error) {
+	return s.accounts.ListByUser(ctx, userID)
+}
+
+// UserAccessToken 返回用户在租户 domain 下有效的 user_access_token，即将过期时用刷新令牌换取新令牌并保存。
+// 未关联飞书账号时返回 ErrNotLinked，刷新令牌失效时返回 ErrReauthorize
+func (s *Service) UserAccessToken(ctx context.Context, userID int, domain string) (string, error) {
+	return s.accessToken(ctx, userID, domain, refreshBefore)
+}
+
+// accessToken 返回用户的访问令牌，令牌在 within 内过期时先刷新
+func (s *Service) accessToken(ctx context.Context, userID int, domain string, within time.Duration) (string, error) {
+	mu := s.lock(userID, domain)
+	mu.Lock()
+	defer mu.Unlock()
+
+	account, err := s.Account(ctx, userID, domain)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if now.Before(account.AccessExpiresAt.Add(-within)) {
+		return account.AccessToken, nil
+	}
+	if account.RefreshToken == "" || (account.RefreshExpiresAt != nil && !now.Before(*account.RefreshExpiresAt)) {
+		return "", ErrReauthorize
+	}
+	token, err := s.feishu.RefreshAccessToken(domain, account.RefreshToken)
+	if err != nil {
+		// 其他实例可能已用同一个刷新令牌完成刷新，重新读取后令牌有效则直接使用
+		if latest, findErr := s.Account(ctx, userID, domain); findErr == nil && latest.AccessToken != account.AccessToken &&
+			now.Before(latest.AccessExpiresAt) {
+			return latest.AccessToken, nil
+		}
+		// 飞书拒绝了刷新令牌（已使用、已撤销或过期），清除后不再重试，等待用户重新授权
+		var apiErr *auth.APIError
+		if errors.As(err, &apiErr) {
+			account.RefreshToken = ""
+			account.UpdatedAt = now
+			if err := s.accounts.Update(ctx, account); err != nil {
+				logger.L.Warn("Failed to clear rejected feishu refresh token", zap.Int("user_id", userID), zap.Error(err))
+			}
+			return "", fmt.Errorf("%w: %v", ErrReauthorize, err)
+		}
+		return "", fmt.Errorf("refresh feishu token: %w", err)
+	}
+	setToken(account, token, now)
+	if err := s.accounts.Update(ctx, account); err != nil {
+		return "", fmt.Errorf("保存飞书账号失败: %v", err)
+	}
+	return account.AccessToken, nil
+}
+
+// RefreshExpiring 刷新在 refresh_before 内过期的令牌，返回刷新成功的数量，单个令牌刷新失败只记录日志
+func (s *Service) RefreshExpiring(ctx context.Context) (int, error) {
+	now := time.Now()
+	accounts, err := s.accounts.ListRefreshable(ctx, now.Add(s.config.RefreshBefore), now, refreshBatch)
+	if err != nil {
+		return 0, fmt.Errorf("查询待刷新的飞书令牌失败: %v", err)
+	}
+	refreshed := 0
+	for _, account := range accounts {
+		if _, err := s.accessToken(ctx, account.UserID, account.Domain, s.config.RefreshBefore); err != nil {
+			logger.L.Warn("Failed to refresh feishu token",
+				zap.Int("user_id", account.UserID), zap.String("domain", account.Domain), zap.Error(err))
+			continue
+		}
+		refreshed++
+	}
+	return refreshed, nil
+}
+
+// Start 在后台每隔 refresh_interval 刷新即将过期的令牌，直到 ctx 结束；refresh_interval 为 0 时不启动
+func (s *Service) Start(ctx context.Context) {
+	interval := s.config.RefreshInterval
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.refreshRound(ctx, interval)
+			}
+		}
+	}()
+}
+
+// refreshRound 执行一轮后台刷新，锁在本轮间隔内有效，其他实例本轮跳过
+func (s *Service) refreshRound(ctx context.Context, interval time.Duration) {
+	ok, err := s.locker.SetNX(ctx, refreshLockKey, "1", interval)
+	if err != nil {
+		logger.L.Warn("Failed to acquire feishu token refresh lock", zap.Error(err))
+		return
+	}
+	if !ok {
+		return
+	}
+	refreshed, err := s.RefreshExpiring(ctx)
+	if err != nil {
+		logger.L.Error("Feishu token refresh failed", zap.Error(err))
+		return
+	}
+	if refreshed > 0 {
+		logger.L.Info("Refreshed feishu tokens", zap.Int("count", refreshed))
+	}
+}
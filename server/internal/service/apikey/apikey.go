@@ -0,0 +1,214 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"feishu2md/server/internal/logger"
+	"feishu2md/server/internal/model"
+	"feishu2md/server/internal/repository/database"
+	"feishu2md/server/pkg/conf"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyPrefix API Key 明文的前缀，用于与 JWT 区分
+const KeyPrefix = "f2m_"
+
+// prefixLen 保存到数据库、用于辨认 Key 的明文长度
+const prefixLen = len(KeyPrefix) + 6
+
+// ErrInvalidKey Key 不存在、格式错误或已吊销
+var ErrInvalidKey = errors.New("invalid api key")
+
+// Repository API Key 的存储，由 database.APIKeyRepository 实现
+type Repository interface {
+	Create(ctx context.Context, key *model.APIKey) error
+	FindActiveByHash(ctx context.Context, hash string) (*model.APIKey, error)
+	CountActiveByUser(ctx context.Context, userID int) (int, error)
+	ListByUser(ctx context.Context, userID int) ([]model.APIKey, error)
+	Revoke(ctx context.Context, userID, id int, at time.Time) error
+	Touch(ctx context.Context, id int, at time.Time) error
+}
+
+// Counter 固定窗口计数器，由 cache.Failover 实现
+type Counter interface {
+	IncrWindow(ctx context.Context, key string, window time.Duration) (int64, error)
+}
+
+// Service 管理 API Key，并按 Key 的限流与每日配额计数
+type Service struct {
+	repo    Repository
+	counter Counter
+	config  conf.APIKeyConfig
+}
+
+// NewAPIKeyService 创建 Service 实例
+func NewAPIKeyService(repo Repository, counter Counter, config conf.APIKeyConfig) *Service {
+	return &Service{repo: repo, counter: counter, config: config}
+}
+
+// hash Key 的 SHA-256，Key 本身是 32 字节随机数，无需加盐与慢哈希
+func hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func invalidParam(message string) error {
+	return &model.ErrorResponse{Code: 1000, Message: "参数错误: " + message}
+}
+
+// Create 为用户创建 API Key，admin 权限只有 isAdmin 为 true（请求携带了管理令牌）时才能授予
+func (s *Service) Create(userID int, req model.CreateAPIKeyRequest, isAdmin bool) (*model.CreatedAPIKey, error) {
+	ctx := context.Background()
+	seen := make(map[string]bool)
+	var scopes []string
+	for _, scope := range req.Scopes {
+		if !validScope(scope) {
+			return nil, invalidParam(fmt.Sprintf("unknown scope %q, want one of %s", scope, strings.Join(model.Scopes, ", ")))
+		}
+		if scope == model.ScopeAdmin && !isAdmin {
+			return nil, &model.ErrorResponse{Code: http.StatusForbidden, Message: "授予 admin 权限需要管理令牌"}
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	rateLimit, err := limit("rate_limit", req.RateLimit, s.config.RateLimit, isAdmin)
+	if err != nil {
+		return nil, err
+	}
+	dailyQuota, err := limit("daily_quota", req.DailyQuota, s.config.DailyQuota, isAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.config.MaxPerUser > 0 {
+		count, err := s.repo.CountActiveByUser(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("统计 API Key 失败: %v", err)
+		}
+		if count >= s.config.MaxPerUser {
+			return nil, &model.ErrorResponse{Code: 1004, Message: fmt.Sprintf("每个用户最多 %d 个 API Key", s.config.MaxPerUser)}
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	plain := KeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	key := model.APIKey{
+		UserID:     userID,
+		Name:       strings.TrimSpace(req.Name),
+		Prefix:     plain[:prefixLen],
+		Hash:       hash(plain),
+		Scopes:     scopes,
+		RateLimit:  rateLimit,
+		DailyQuota: dailyQuota,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.repo.Create(ctx, &key); err != nil {
+		return nil, fmt.Errorf("保存 API Key 失败: %v", err)
+	}
+	return &model.CreatedAPIKey{APIKey: key, Key: plain}, nil
+}
+
+// limit 校验请求中的限流或配额，未指定时使用配置的默认值 def。普通用户只能在 1..def 之间收紧，
+// 不能放宽或设为 0（不限制）；def 为 0 时默认已不限制，不再约束。管理令牌可以设置任意非负值
+func limit(name string, value *int, def int, isAdmin bool) (int, error) {
+	if value == nil {
+		return def, nil
+	}
+	v := *value
+	if v < 0 {
+		return 0, invalidParam(name + " must not be negative")
+	}
+	if !isAdmin && def > 0 && (v == 0 || v > def) {
+		return 0, &model.ErrorResponse{Code: http.StatusForbidden,
+			Message: fmt.Sprintf("%s 必须在 1 到 %d 之间，更大的值或不限制需要管理令牌", name, def)}
+	}
+	return v, nil
+}
+
+func validScope(scope string) bool {
+	for _, s := range model.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// List 返回用户的全部 API Key
+func (s *Service) List(userID int) ([]model.APIKey, error) {
+	keys, err := s.repo.ListByUser(context.Background(), userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询 API Key 失败: %v", err)
+	}
+	return keys, nil
+}
+
+// Revoke 吊销用户的一个 API Key，立即生效
+func (s *Service) Revoke(userID, id int) error {
+	err := s.repo.Revoke(context.Background(), userID, id, time.Now())
+	if errors.Is(err, database.ErrNotFound) {
+		return &model.ErrorResponse{Code: http.StatusNotFound, Message: "API Key 不存在"}
+	}
+	if err != nil {
+		return fmt.Errorf("吊销 API Key 失败: %v", err)
+	}
+	return nil
+}
+
+// Authenticate 校验明文 Key，返回未吊销的 Key
+func (s *Service) Authenticate(ctx context.Context, plain string) (*model.APIKey, error) {
+	if !strings.HasPrefix(plain, KeyPrefix) {
+		return nil, ErrInvalidKey
+	}
+	key, err := s.repo.FindActiveByHash(ctx, hash(plain))
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Touch(ctx, key.ID, time.Now()); err != nil {
+		logger.L.Warn("Failed to record api key usage", zap.Int("id", key.ID), zap.Error(err))
+	}
+	return key, nil
+}
+
+// Allow 按 Key 的每分钟限流与每日配额计数，超出时返回 code 为 429 的 *model.ErrorResponse
+func (s *Service) Allow(ctx context.Context, key *model.APIKey) error {
+	now := time.Now()
+	id := strconv.Itoa(key.ID)
+	if key.RateLimit > 0 {
+		n, err := s.counter.IncrWindow(ctx, "apikey:"+id+":rate:"+now.Format("200601021504"), time.Minute)
+		if err != nil {
+			return fmt.Errorf("api key rate limit: %w", err)
+		}
+		if n > int64(key.RateLimit) {
+			return &model.ErrorResponse{Code: http.StatusTooManyRequests, Message: fmt.Sprintf("请求过于频繁，每分钟最多 %d 次", key.RateLimit)}
+		}
+	}
+	if key.DailyQuota > 0 {
+		// 多保留一小时，避免窗口边界上计数提前过期
+		n, err := s.counter.IncrWindow(ctx, "apikey:"+id+":quota:"+now.Format("20060102"), 25*time.Hour)
+		if err != nil {
+			return fmt.Errorf("api key quota: %w", err)
+		}
+		if n > int64(key.DailyQuota) {
+			return &model.ErrorResponse{Code: http.StatusTooManyRequests, Message: fmt.Sprintf("今日配额已用完，每天最多 %d 次", key.DailyQuota)}
+		}
+	}
+	return nil
+}
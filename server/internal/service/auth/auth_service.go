@@ -4,37 +4,43 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
-	"feishu2md/server/internal/config"
 	"feishu2md/server/internal/model"
+	"feishu2md/server/internal/tenant"
 	"fmt"
 	"net/http"
 	"time"
 )
 
+// APIError 飞书 OAuth 接口返回的错误，如授权码或刷新令牌无效
+type APIError struct {
+	Code        int
+	Err         string
+	Description string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("feishu error: %d - %s - %s", e.Code, e.Err, e.Description)
+}
+
+// Service 飞书 OAuth 接口，按域名使用对应租户的应用凭证与开放平台地址
 type Service struct {
-	client *http.Client
+	client  *http.Client
+	tenants *tenant.Registry
 }
 
-func NewAuthService() *Service {
+func NewAuthService(tenants *tenant.Registry) *Service {
 	return &Service{
-		client: &http.Client{Timeout: 10 * time.Second},
+		client:  &http.Client{Timeout: 10 * time.Second},
+		tenants: tenants,
 	}
 }
-func (s *Service) GetAccessToken(req *model.TokenRequest) (*model.FeishuTokenResponse, error) {
-	cfg := config.LoadConfig()
-	clientID := cfg.Feishu.AppID
-	clientSecret := cfg.Feishu.AppSecret
-
-	if clientID == "" || clientSecret == "" {
-		return nil, errors.New("missing feishu credentials")
-	}
 
+// GetAccessToken 用授权码换取 user_access_token，req.Domain 为空时使用默认租户
+func (s *Service) GetAccessToken(req *model.TokenRequest) (*model.FeishuTokenResponse, error) {
 	// 构造飞书请求体
 	payload := map[string]interface{}{
-		"grant_type":    "authorization_code",
-		"client_id":     clientID,
-		"client_secret": clientSecret,
-		"code":          req.Code,
+		"grant_type": "authorization_code",
+		"code":       req.Code,
 	}
 
 	// 可选参数处理
@@ -47,11 +53,40 @@ func (s *Service) GetAccessToken(req *model.TokenRequest) (*model.FeishuTokenRes
 	if req.Scope != "" {
 		payload["scope"] = req.Scope
 	}
+	t, err := s.tenants.Resolve(req.Domain)
+	if err != nil {
+		return nil, err
+	}
+	return s.requestToken(t, payload)
+}
+
+// RefreshAccessToken 用刷新令牌换取新的 user_access_token，飞书的刷新令牌只能使用一次
+func (s *Service) RefreshAccessToken(domain, refreshToken string) (*model.FeishuTokenResponse, error) {
+	t, err := s.tenants.Resolve(domain)
+	if err != nil {
+		return nil, err
+	}
+	return s.requestToken(t, map[string]interface{}{
+		"grant_type":    "refresh_token",
+		"refresh_token": refreshToken,
+	})
+}
+
+// requestToken 调用租户的 OAuth 令牌接口
+func (s *Service) requestToken(t tenant.Tenant, payload map[string]interface{}) (*model.FeishuTokenResponse, error) {
+	clientID := t.AppID
+	clientSecret := t.AppSecret
+
+	if clientID == "" || clientSecret == "" {
+		return nil, errors.New("missing feishu credentials")
+	}
+	payload["client_id"] = clientID
+	payload["client_secret"] = clientSecret
 	jsonData, _ := json.Marshal(payload)
 
 	// 调用飞书API
 	resp, err := s.client.Post(
-		"https://open.feishu.cn/open-apis/authen/v2/oauth/token",
+		t.OpenBaseURL+"/open-apis/authen/v2/oauth/token",
 		"application/json",
 		bytes.NewBuffer(jsonData),
 	)
@@ -68,8 +103,42 @@ func (s *Service) GetAccessToken(req *model.TokenRequest) (*model.FeishuTokenRes
 
 	// 处理飞书错误码
 	if result.Code != 0 {
-		return nil, fmt.Errorf("feishu error: %d - %s - %s", result.Code, result.Err, result.ErrorDescription)
+		return nil, &APIError{Code: result.Code, Err: result.Err, Description: result.ErrorDescription}
 	}
 
 	return &result, nil
 }
+
+// GetUserInfo 获取 user_access_token 对应的飞书用户信息
+func (s *Service) GetUserInfo(domain, accessToken string) (*model.FeishuUserInfo, error) {
+	t, err := s.tenants.Resolve(domain)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, t.OpenBaseURL+"/open-apis/authen/v1/user_info", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Code int                  `json:"code"`
+		Msg  string               `json:"msg"`
+		Data model.FeishuUserInfo `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if result.Code != 0 {
+		return nil, fmt.Errorf("feishu error: %d - %s", result.Code, result.Msg)
+	}
+	if result.Data.OpenID == "" {
+		return nil, errors.New("feishu error: user info without open_id")
+	}
+	return &result.Data, nil
+}
@@ -1,7 +1,7 @@
 package captcha
 
 import (
-	"feishu2md/server/internal/config"
+	"feishu2md/server/pkg/conf"
 	"github.com/mojocn/base64Captcha"
 	"image/color"
 	"log"
@@ -9,32 +9,31 @@ import (
 	"time"
 )
 
-var result base64Captcha.Store
-
 type CaptService struct {
+	cfg   conf.CaptchaConfig
+	store base64Captcha.Store
 }
 
-func NewCaptService() *CaptService {
-	return &CaptService{}
-}
-func init() {
-	cfg := config.LoadConfig()
-	expire := cfg.CptConfig.ExpireTime
+// NewCaptService 创建验证码服务，验证码保存在服务自身的内存中，需在进程内复用同一实例
+func NewCaptService(cfg conf.CaptchaConfig) *CaptService {
+	expire := cfg.ExpireTime
 	if expire == 0 {
 		expire = 180 // 默认3分钟
 	}
-	maxStore := cfg.CptConfig.MaxStore
+	maxStore := cfg.MaxStore
 	if maxStore == 0 {
 		maxStore = 20240
 	}
 
-	result = base64Captcha.NewMemoryStore(maxStore, expire*time.Second)
+	return &CaptService{
+		cfg:   cfg,
+		store: base64Captcha.NewMemoryStore(maxStore, expire*time.Second),
+	}
 }
 func (s *CaptService) CreateCode() (string, string, error) {
 	var driver base64Captcha.Driver
-	cfg := config.LoadConfig()
 	var driverType string
-	if cfg.CptConfig.RandomCaptcha {
+	if s.cfg.RandomCaptcha {
 		// 随机模式
 		driverList := []struct {
 			Type   string
@@ -50,7 +49,7 @@ func (s *CaptService) CreateCode() (string, string, error) {
 		driverType = selected.Type
 	} else {
 		// 固定模式
-		driverType = cfg.CptConfig.CaptchaType
+		driverType = s.cfg.CaptchaType
 		switch driverType {
 		case "string":
 			driver = s.stringConfig()
@@ -63,7 +62,7 @@ func (s *CaptService) CreateCode() (string, string, error) {
 		}
 	}
 
-	c := base64Captcha.NewCaptcha(driver, result)
+	c := base64Captcha.NewCaptcha(driver, s.store)
 	id, b64s, err := c.Generate()
 	if err != nil {
 		log.Printf("[Captcha] 生成失败: %v\n", err)
@@ -74,11 +73,11 @@ func (s *CaptService) CreateCode() (string, string, error) {
 }
 
 func (s *CaptService) VerifyCaptcha(id, verifyValue string) bool {
-	return result.Verify(id, verifyValue, true)
+	return s.store.Verify(id, verifyValue, true)
 }
 
 func (s *CaptService) GetCodeAnswer(id string) string {
-	return result.Get(id, false)
+	return s.store.Get(id, false)
 }
 
 // mathConfig 生成图形化算术验证码配置
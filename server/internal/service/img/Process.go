@@ -3,70 +3,105 @@ package img
 import (
 	"bytes"
 	"context"
+	"errors"
 	"feishu2md/server/internal/feishu"
 	"feishu2md/server/internal/logger"
 	"feishu2md/server/internal/model"
+	"feishu2md/server/internal/progress"
+	"feishu2md/server/internal/ratelimit"
 	"feishu2md/server/internal/repository/cache"
 	"feishu2md/server/internal/repository/storage"
 	"feishu2md/server/pkg/conf"
-	"feishu2md/server/pkg/utils"
 	"fmt"
 	"go.uber.org/zap"
-	"log"
+	"path"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
 type Processor struct {
-	cache     cache.RedisCache      // 缓存接口
+	cache     cache.Cache           // 缓存接口
 	storage   storage.ObjectStorage // 对象存储
 	client    feishu.Client         //飞书客户端
-	imgConfig conf.ImgConfig
+	imgConfig atomic.Pointer[conf.ImgConfig]
 }
 
 func NewProcessor(
-	cache cache.RedisCache,
+	cache cache.Cache,
 	storage storage.ObjectStorage,
 	client feishu.Client,
 	cfg conf.ImgConfig,
 ) *Processor {
-	return &Processor{
-		cache:     cache,
-		storage:   storage,
-		client:    client,
-		imgConfig: cfg,
+	p := &Processor{
+		cache:   cache,
+		storage: storage,
+		client:  client,
 	}
+	p.SetConfig(cfg)
+	return p
 }
-func (p *Processor) ProcessImages(ctx context.Context, markdown string, tokens []string, req model.Req) (string, error) {
+
+// SetConfig 更新下载并发、限流等待与图片处理配置，对之后开始的 ProcessImages 生效
+func (p *Processor) SetConfig(cfg conf.ImgConfig) {
+	p.imgConfig.Store(&cfg)
+}
+
+// ErrImagesFailed 严格模式下有图片处理失败
+var ErrImagesFailed = errors.New("图片处理失败")
+
+// ProcessImages 下载文档中的图片并上传到 userID 名下的存储空间，将 Markdown 中的图片 token 替换为访问链接，
+// 返回与 tokens 顺序相同的处理结果；失败图片的 token 按 image.failure_placeholder 替换，
+// 严格模式下有图片失败时同时返回 ErrImagesFailed
+func (p *Processor) ProcessImages(ctx context.Context, markdown string, tokens []string, userID int, req model.Req) (string, []model.ImageResult, error) {
+	imgConfig := p.imgConfig.Load()
 	var (
 		wg           sync.WaitGroup
 		successCount int64
-		limiter      = make(chan struct{}, p.imgConfig.DownloadRate)
+		doneCount    int64
+		limiter      = make(chan struct{}, imgConfig.DownloadRate)
 		mtx          sync.Mutex
 		result       = []byte(markdown)
+		images       = make([]model.ImageResult, len(tokens))
 	)
 
 	startTime := time.Now()
 	total := len(tokens)
 
-	for _, token := range tokens {
+	for i, token := range tokens {
 		wg.Add(1)
 		limiter <- struct{}{}
 
-		go func(t string) {
+		go func(i int, t string) {
 			defer func() {
 				<-limiter
 				wg.Done()
 			}()
 
-			if url := p.processSingleImage(ctx, token, p.imgConfig.MaxRetries, p.imgConfig.MaxWaitTime, req); url != "" {
+			image := p.processSingleImage(ctx, t, imgConfig, userID, req)
+			images[i] = image
+			replacement := image.URL
+			if image.Status == model.ImageFailed {
+				replacement = imgConfig.FailurePlaceholder
+			} else {
 				atomic.AddInt64(&successCount, 1)
+			}
+			if replacement != "" {
 				mtx.Lock()
-				result = bytes.Replace(result, []byte(t), []byte(url), 1)
+				result = bytes.Replace(result, []byte(t), []byte(replacement), 1)
 				mtx.Unlock()
 			}
-		}(token)
+			progress.Report(ctx, progress.Event{
+				Stage:  progress.StageImage,
+				Token:  t,
+				Status: image.Status,
+				Error:  image.Error,
+				Done:   int(atomic.AddInt64(&doneCount, 1)),
+				Total:  total,
+			})
+		}(i, token)
 	}
 
 	wg.Wait()
@@ -77,51 +112,119 @@ func (p *Processor) ProcessImages(ctx context.Context, markdown string, tokens [
 		zap.Duration("duration", time.Since(startTime)),
 	)
 
-	return string(result), nil
+	strict := imgConfig.Strict
+	if req.StrictImages != nil {
+		strict = *req.StrictImages
+	}
+	if failed := int64(total) - successCount; strict && failed > 0 {
+		return string(result), images, fmt.Errorf("%w: %d of %d images failed", ErrImagesFailed, failed, total)
+	}
+	return string(result), images, nil
 }
 
-func (p *Processor) processSingleImage(ctx context.Context, token string, maxRetries int, maxWaitTime time.Duration, req model.Req) string {
-	// 1. 检查缓存
-	if url, _ := p.cache.GetURL(ctx, token); url != "" {
-		return url
+func (p *Processor) processSingleImage(ctx context.Context, token string, imgConfig *conf.ImgConfig, userID int, req model.Req) model.ImageResult {
+	image := model.ImageResult{Token: token, Status: model.ImageFailed}
+	opts := imgConfig.Process
+	if req.ImageProcess != nil {
+		opts = *req.ImageProcess
 	}
-	log.Println("程序运行到 processSingleImage 函数中")
-	//启用全局令牌桶进行限流，保证全局请求不超过5QPS
-	if !p.cache.RetryDownloadRateLimit(ctx, token, maxRetries, maxWaitTime) {
-		logger.L.Warn("下载被限流", zap.String("token", token))
-		return "" // 超过重试次数或等待超时，跳过该图片
+
+	// 1. 检查缓存，同一图片上传到不同用户的存储空间，缓存按用户区分；处理选项不同的结果分别缓存
+	cacheKey := fmt.Sprintf("img:%d:%s", userID, token) + processKey(opts)
+	thumbnailKey := cacheKey + ":thumb"
+	if url, _ := p.cache.GetURL(ctx, cacheKey); url != "" {
+		image.Status, image.URL = model.ImageCached, url
+		if opts.ThumbnailWidth > 0 {
+			image.ThumbnailURL, _ = p.cache.GetURL(ctx, thumbnailKey)
+		}
+		return image
 	}
-	log.Println("程序运行到 processSingleImage 函数后")
 
-	// 3. 下载并上传
-	downloadImageRaw := func() (string, []byte, error) {
-		return p.client.DownloadImageRaw(ctx, token, fmt.Sprintf("%s/%s", "images", req.Collection), req.UserAccessToken)
+	// 2. 下载并上传，下载速率由飞书客户端按 media 类别限流，等待额度超过 maxWaitTime 时跳过该图片；
+	// 限流与服务端错误由飞书客户端统一重试，重试次数计入 Attempts
+	downloadCtx, attempts := feishu.CountAttempts(ratelimit.WithMaxWait(ctx, imgConfig.MaxWaitTime))
+	filename, content, err := p.client.DownloadImageRaw(downloadCtx, token, imageDir(userID, req.Collection), req.UserAccessToken)
+	image.Attempts = attempts()
+	if errors.Is(err, ratelimit.ErrWaitExceeded) {
+		logger.L.Warn("下载被限流", zap.String("token", token))
+		image.Error = model.ImageErrRateLimited
+		return image
 	}
-	filename, content, err := utils.ExponentialBackoff(ctx, 0, maxRetries, maxWaitTime, downloadImageRaw)
 	if err != nil {
 		logger.L.Error("图片下载失败",
 			zap.String("token", token),
 			zap.Error(err),
 		)
-		return ""
+		image.Error = downloadError(err)
+		return image
 	}
 
-	url, err := p.storage.Upload(ctx, filename, content)
+	// 3. 按选项缩放与转换格式，处理失败时上传原图
+	processed, err := postProcess(ctx, filename, content, opts, imgConfig)
+	if err != nil {
+		logger.L.Warn("图片转换失败，上传原图",
+			zap.String("token", token),
+			zap.Error(err),
+		)
+		processed = &processedImage{filename: filename, content: content}
+	}
+
+	url, err := p.storage.Upload(ctx, processed.filename, processed.content)
 	if err != nil {
 		logger.L.Error("图片上传失败",
 			zap.String("token", token),
 			zap.Error(err),
 		)
-		return ""
+		image.Error = model.ImageErrUpload
+		if ctx.Err() != nil {
+			image.Error = model.ImageErrCanceled
+		}
+		return image
+	}
+
+	// 4. 缩略图上传失败不影响原图
+	if processed.thumbnail != nil {
+		if image.ThumbnailURL, err = p.storage.Upload(ctx, processed.thumbnailName, processed.thumbnail); err != nil {
+			logger.L.Warn("缩略图上传失败",
+				zap.String("token", token),
+				zap.Error(err),
+			)
+		} else if err := p.cache.SetURL(ctx, thumbnailKey, image.ThumbnailURL); err != nil {
+			logger.L.Warn("缓存更新失败",
+				zap.String("token", token),
+				zap.Error(err),
+			)
+		}
 	}
 
-	// 4. 更新缓存
-	if err := p.cache.SetURL(ctx, token, url); err != nil {
+	// 5. 更新缓存
+	if err := p.cache.SetURL(ctx, cacheKey, url); err != nil {
 		logger.L.Warn("缓存更新失败",
 			zap.String("token", token),
 			zap.Error(err),
 		)
 	}
 
-	return url
+	image.Status, image.URL = model.ImageCompleted, url
+	return image
+}
+
+// downloadError 下载失败的原因
+func downloadError(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return model.ImageErrCanceled
+	case errors.Is(err, feishu.ErrRateLimited):
+		return model.ImageErrRateLimited
+	case errors.Is(err, feishu.ErrNoPermission):
+		return model.ImageErrNoPermission
+	case errors.Is(err, feishu.ErrNotFound):
+		return model.ImageErrNotFound
+	}
+	return model.ImageErrDownload
+}
+
+// imageDir 图片在存储中的目录，collection 来自请求，清理后不能跳出用户目录
+func imageDir(userID int, collection string) string {
+	return path.Join("users", strconv.Itoa(userID), "images", strings.TrimPrefix(path.Clean("/"+collection), "/"))
 }
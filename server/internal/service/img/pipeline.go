@@ -0,0 +1,216 @@
+package img
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"feishu2md/server/pkg/conf"
+	"fmt"
+	_ "golang.org/x/image/bmp" // 注册解码器
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultQuality 未指定压缩质量时使用
+	defaultQuality = 85
+	// defaultMaxPixels 未配置 image.max_pixels 时处理的图片像素数上限，解码后约占 100 MB 内存
+	defaultMaxPixels = 25_000_000
+)
+
+var (
+	// errWebPUnavailable 没有配置 cwebp，不能输出 WebP
+	errWebPUnavailable = errors.New("cwebp_path is not configured")
+	// errTooManyPixels 图片像素数超过上限，不解码
+	errTooManyPixels = errors.New("image exceeds image.max_pixels")
+)
+
+// ValidateProcess 检查请求中的图片处理选项，cwebp 为配置的 cwebp 路径，未配置时不能转换为 WebP
+func ValidateProcess(opts conf.ImageProcessConfig, cwebp string) error {
+	switch {
+	case opts.MaxWidth < 0, opts.ThumbnailWidth < 0:
+		return errors.New("image_process: width must not be negative")
+	case opts.Quality < 0 || opts.Quality > 100:
+		return errors.New("image_process: quality must be between 0 and 100")
+	}
+	switch opts.Format {
+	case "", "jpeg", "png":
+		return nil
+	case "webp":
+		if cwebp == "" {
+			return errors.New("image_process: webp is not available, image.cwebp_path is not configured")
+		}
+		return nil
+	}
+	return fmt.Errorf("image_process: unsupported format %q, want jpeg, png or webp", opts.Format)
+}
+
+// processKey 图片处理选项在缓存 key 中的部分，不处理时为空，与未引入图片处理前的缓存兼容
+func processKey(opts conf.ImageProcessConfig) string {
+	if opts == (conf.ImageProcessConfig{}) {
+		return ""
+	}
+	key := fmt.Sprintf(":w%d:%s:q%d:t%d", opts.MaxWidth, opts.Format, quality(opts), opts.ThumbnailWidth)
+	if opts.StripMetadata {
+		key += ":s"
+	}
+	return key
+}
+
+func quality(opts conf.ImageProcessConfig) int {
+	if opts.Quality == 0 {
+		return defaultQuality
+	}
+	return opts.Quality
+}
+
+// processedImage 处理后的图片与缩略图，没有生成缩略图时 thumbnail 为空
+type processedImage struct {
+	filename      string
+	content       []byte
+	thumbnailName string
+	thumbnail     []byte
+}
+
+// postProcess 按选项缩放、转换格式并去除元数据，无需处理的图片原样返回；
+// GIF 可能包含动画，不做处理；先读取尺寸，像素数超过 image.max_pixels 的图片不解码
+func postProcess(ctx context.Context, filename string, content []byte, opts conf.ImageProcessConfig, cfg *conf.ImgConfig) (*processedImage, error) {
+	result := &processedImage{filename: filename, content: content}
+	if opts == (conf.ImageProcessConfig{}) {
+		return result, nil
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	if format == "gif" {
+		return result, nil
+	}
+	maxPixels := cfg.MaxPixels
+	if maxPixels == 0 {
+		maxPixels = defaultMaxPixels
+	}
+	if int64(config.Width)*int64(config.Height) > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d", errTooManyPixels, config.Width, config.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+
+	target := opts.Format
+	if target == "" {
+		target = outputFormat(format, cfg.CWebPPath)
+	}
+	base := strings.TrimSuffix(filename, path.Ext(filename))
+	width := src.Bounds().Dx()
+	resized := opts.MaxWidth > 0 && width > opts.MaxWidth
+	converted := opts.Format != "" && opts.Format != format
+	if resized || converted || opts.StripMetadata {
+		img := src
+		if resized {
+			img = resize(src, opts.MaxWidth)
+		}
+		if result.content, err = encode(ctx, img, target, quality(opts), cfg.CWebPPath); err != nil {
+			return nil, err
+		}
+		result.filename = base + "." + extension(target)
+	}
+	if opts.ThumbnailWidth > 0 && width > opts.ThumbnailWidth {
+		if result.thumbnail, err = encode(ctx, resize(src, opts.ThumbnailWidth), target, quality(opts), cfg.CWebPPath); err != nil {
+			return nil, err
+		}
+		result.thumbnailName = base + "_thumb." + extension(target)
+	}
+	return result, nil
+}
+
+// outputFormat 未指定格式时重新编码使用的格式：保持原格式，无法编码的格式（如 BMP，或未配置 cwebp 时的 WebP）改为 PNG
+func outputFormat(source, cwebp string) string {
+	switch {
+	case source == "jpeg", source == "png":
+		return source
+	case source == "webp" && cwebp != "":
+		return source
+	}
+	return "png"
+}
+
+// extension 格式对应的文件扩展名
+func extension(format string) string {
+	if format == "jpeg" {
+		return "jpg"
+	}
+	return format
+}
+
+// resize 等比缩放到指定宽度
+func resize(src image.Image, width int) image.Image {
+	bounds := src.Bounds()
+	height := max(bounds.Dy()*width/bounds.Dx(), 1)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+	return dst
+}
+
+// encode 按格式编码，JPEG 不支持透明，透明部分以白色填充
+func encode(ctx context.Context, img image.Image, format string, quality int, cwebp string) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "jpeg":
+		bounds := img.Bounds()
+		opaque := image.NewRGBA(bounds)
+		draw.Draw(opaque, bounds, image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(opaque, bounds, img, bounds.Min, draw.Over)
+		if err := jpeg.Encode(&buf, opaque, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, err
+		}
+	case "png":
+		encoder := png.Encoder{CompressionLevel: png.BestCompression}
+		if err := encoder.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+	case "webp":
+		return encodeWebP(ctx, img, quality, cwebp)
+	default:
+		return nil, fmt.Errorf("unsupported output format %q", format)
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeWebP 标准库没有 WebP 编码器，先写出无损 PNG，再调用 cwebp 转换
+func encodeWebP(ctx context.Context, img image.Image, quality int, cwebp string) ([]byte, error) {
+	if cwebp == "" {
+		return nil, errWebPUnavailable
+	}
+	dir, err := os.MkdirTemp("", "feishu2md-webp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input, output := filepath.Join(dir, "input.png"), filepath.Join(dir, "output.webp")
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(input, buf.Bytes(), 0600); err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, cwebp, "-quiet", "-metadata", "none", "-q", strconv.Itoa(quality), input, "-o", output)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("cwebp: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return os.ReadFile(output)
+}
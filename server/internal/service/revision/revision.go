@@ -0,0 +1,243 @@
+package revision
+
+import (
+	"context"
+	"encoding/json"
+	"feishu2md/server/internal/feishu"
+	"feishu2md/server/internal/model"
+	"fmt"
+	"github.com/chyroc/lark"
+	"github.com/pmezard/go-difflib/difflib"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	RevisionLatest   = "latest"
+	RevisionPrevious = "previous"
+
+	// 块摘要的最大长度（按字符计算）
+	maxSummaryLen = 200
+)
+
+// Service 文档版本导出与对比
+type Service struct {
+	client *feishu.Client
+}
+
+// NewRevisionService 创建 Service 实例
+func NewRevisionService(client *feishu.Client) *Service {
+	return &Service{client: client}
+}
+
+// Diff 导出文档的两个版本并返回 Markdown 差异与块级别变更列表
+func (s *Service) Diff(ctx context.Context, docToken, userAccessToken, fromSpec, toSpec string) (*model.RevisionDiffResult, *feishu.DocumentRevision, *feishu.DocumentRevision, error) {
+	// 只需要最新版本号，不获取文档块
+	docx, err := s.client.GetDocxDocument(ctx, docToken, userAccessToken)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("获取文档最新版本失败: %w", err)
+	}
+	fromID, toID, err := ResolveRevisions(fromSpec, toSpec, docx.RevisionID)
+	if err != nil {
+		return nil, nil, nil, &model.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid revision",
+			Detail:  err.Error(),
+		}
+	}
+
+	from, err := s.client.GetDocumentRevision(ctx, docToken, userAccessToken, fromID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("导出版本 %d 失败: %w", fromID, err)
+	}
+	to, err := s.client.GetDocumentRevision(ctx, docToken, userAccessToken, toID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("导出版本 %d 失败: %w", toID, err)
+	}
+
+	result := &model.RevisionDiffResult{
+		Title:        to.Content.DocTitle,
+		FromRevision: fromID,
+		ToRevision:   toID,
+		UnifiedDiff: UnifiedDiff(from.Content.Markdown, to.Content.Markdown,
+			fmt.Sprintf("revision %d", fromID), fmt.Sprintf("revision %d", toID)),
+		Changes: DiffBlocks(from.Blocks, to.Blocks),
+	}
+	return result, from, to, nil
+}
+
+// ResolveRevisions 将版本描述解析为具体的版本号
+// 空值与 "latest" 表示最新版本，"previous" 表示另一端的上一个版本
+func ResolveRevisions(fromSpec, toSpec string, latest int64) (int64, int64, error) {
+	if fromSpec == "" {
+		fromSpec = RevisionPrevious
+	}
+	if fromSpec == RevisionPrevious && toSpec == RevisionPrevious {
+		return 0, 0, fmt.Errorf("from_revision and to_revision cannot both be %q", RevisionPrevious)
+	}
+
+	var fromID, toID int64
+	var err error
+	if toSpec != RevisionPrevious {
+		if toID, err = parseRevision(toSpec, latest); err != nil {
+			return 0, 0, err
+		}
+	}
+	if fromSpec != RevisionPrevious {
+		if fromID, err = parseRevision(fromSpec, latest); err != nil {
+			return 0, 0, err
+		}
+	}
+	if fromSpec == RevisionPrevious {
+		fromID = toID - 1
+	}
+	if toSpec == RevisionPrevious {
+		toID = fromID - 1
+	}
+
+	if fromID < 1 || toID < 1 {
+		return 0, 0, fmt.Errorf("revision out of range, document has revisions 1..%d", latest)
+	}
+	return fromID, toID, nil
+}
+
+func parseRevision(spec string, latest int64) (int64, error) {
+	if spec == "" || spec == RevisionLatest {
+		return latest, nil
+	}
+	id, err := strconv.ParseInt(spec, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid revision %q", spec)
+	}
+	if id < 1 || id > latest {
+		return 0, fmt.Errorf("revision %d out of range, document has revisions 1..%d", id, latest)
+	}
+	return id, nil
+}
+
+// UnifiedDiff 生成两段 Markdown 的统一格式差异
+func UnifiedDiff(before, after, fromName, toName string) string {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(before),
+		B:        difflib.SplitLines(after),
+		FromFile: fromName,
+		ToFile:   toName,
+		Context:  3,
+	})
+	if err != nil {
+		return ""
+	}
+	return diff
+}
+
+// DiffBlocks 按 BlockID 对比两个版本的块，结果按文档顺序排列，删除的块排在最后
+func DiffBlocks(before, after []*lark.DocxBlock) []model.BlockChange {
+	beforeMap := make(map[string]*lark.DocxBlock, len(before))
+	for _, block := range before {
+		beforeMap[block.BlockID] = block
+	}
+	afterSet := make(map[string]struct{}, len(after))
+
+	changes := make([]model.BlockChange, 0)
+	for _, block := range after {
+		afterSet[block.BlockID] = struct{}{}
+		old, ok := beforeMap[block.BlockID]
+		if !ok {
+			changes = append(changes, model.BlockChange{
+				Type:      "added",
+				BlockID:   block.BlockID,
+				BlockType: int64(block.BlockType),
+				After:     blockSummary(block),
+			})
+			continue
+		}
+		if blockSignature(old) != blockSignature(block) {
+			changes = append(changes, model.BlockChange{
+				Type:      "modified",
+				BlockID:   block.BlockID,
+				BlockType: int64(block.BlockType),
+				Before:    blockSummary(old),
+				After:     blockSummary(block),
+			})
+		}
+	}
+	for _, block := range before {
+		if _, ok := afterSet[block.BlockID]; !ok {
+			changes = append(changes, model.BlockChange{
+				Type:      "removed",
+				BlockID:   block.BlockID,
+				BlockType: int64(block.BlockType),
+				Before:    blockSummary(block),
+			})
+		}
+	}
+	return changes
+}
+
+// blockSignature 块内容的签名，忽略块ID与父子关系
+func blockSignature(block *lark.DocxBlock) string {
+	content := *block
+	content.BlockID = ""
+	content.ParentID = ""
+	content.Children = nil
+	data, err := json.Marshal(content)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// blockSummary 提取块的纯文本摘要
+func blockSummary(block *lark.DocxBlock) string {
+	var summary string
+	if text := blockText(block); text != nil {
+		buf := new(strings.Builder)
+		for _, element := range text.Elements {
+			switch {
+			case element.TextRun != nil:
+				buf.WriteString(element.TextRun.Content)
+			case element.MentionDoc != nil:
+				buf.WriteString(element.MentionDoc.Title)
+			case element.Equation != nil:
+				buf.WriteString(element.Equation.Content)
+			}
+		}
+		summary = buf.String()
+	} else {
+		switch {
+		case block.Image != nil:
+			summary = "[Image " + block.Image.Token + "]"
+		case block.Table != nil:
+			summary = "[Table]"
+		case block.Sheet != nil:
+			summary = "[Sheet " + block.Sheet.Token + "]"
+		case block.Bitable != nil:
+			summary = "[Bitable " + block.Bitable.Token + "]"
+		case block.File != nil:
+			summary = "[File " + block.File.Name + "]"
+		}
+	}
+
+	runes := []rune(summary)
+	if len(runes) > maxSummaryLen {
+		return string(runes[:maxSummaryLen]) + "..."
+	}
+	return summary
+}
+
+func blockText(block *lark.DocxBlock) *lark.DocxBlockText {
+	for _, text := range []*lark.DocxBlockText{
+		block.Page, block.Text,
+		block.Heading1, block.Heading2, block.Heading3,
+		block.Heading4, block.Heading5, block.Heading6,
+		block.Heading7, block.Heading8, block.Heading9,
+		block.Bullet, block.Ordered, block.Code,
+		block.Quote, block.Equation, block.Todo,
+	} {
+		if text != nil {
+			return text
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+// Package token 签发与校验登录令牌：短期的 JWT 访问令牌与保存在 Redis 中的刷新令牌。
+// 访问令牌头部带有签名密钥的 kid，密钥轮换后旧令牌在过期前仍可验证；
+// 注销时访问令牌的 jti 写入 Redis 拒绝列表，直到令牌自然过期。
+// Redis 不可用期间刷新令牌与拒绝列表只保存在本实例内。
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"feishu2md/server/pkg/conf"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	refreshKeyPrefix = "jwt:refresh:"
+	denyKeyPrefix    = "jwt:deny:"
+)
+
+var (
+	// ErrInvalidToken 令牌格式错误、签名错误或已过期
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrRevokedToken 令牌已注销
+	ErrRevokedToken = errors.New("token revoked")
+)
+
+// Store 刷新令牌与拒绝列表的存储，由 cache.Failover 实现
+type Store interface {
+	SetEX(ctx context.Context, key, value string, ttl time.Duration) error
+	GetEX(ctx context.Context, key string) (value string, ttl time.Duration, ok bool, err error)
+	Take(ctx context.Context, key string) (string, bool, error)
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+// Claims 访问令牌中的声明
+type Claims struct {
+	UserID int `json:"user_id"`
+	jwt.RegisteredClaims
+}
+
+// Pair 登录或刷新后返回的令牌
+type Pair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	// ExpiresIn 访问令牌的有效期，单位秒
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// Service 令牌服务，配置可在运行时通过 SetConfig 更新以轮换密钥
+type Service struct {
+	config atomic.Pointer[conf.JWTConfig]
+	store  Store
+}
+
+// NewTokenService 创建 Service 实例
+func NewTokenService(config conf.JWTConfig, store Store) *Service {
+	s := &Service{store: store}
+	s.SetConfig(config)
+	return s
+}
+
+// SetConfig 替换签名密钥与有效期，已签发的令牌按新的密钥集合验证
+func (s *Service) SetConfig(config conf.JWTConfig) {
+	s.config.Store(&config)
+}
+
+// randomToken 生成 32 字节随机数的 base64url 编码
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// refreshKey 刷新令牌在 Redis 中的 key，只保存哈希
+func refreshKey(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return refreshKeyPrefix + hex.EncodeToString(sum[:])
+}
+
+// Issue 为用户签发访问令牌与刷新令牌
+func (s *Service) Issue(ctx context.Context, userID int) (*Pair, error) {
+	config := s.config.Load()
+	now := time.Now()
+	jti, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	claims := &Claims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Issuer:    config.Issuer,
+			Subject:   strconv.Itoa(userID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(config.AccessTTL)),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = config.KeyID
+	accessToken, err := token.SignedString([]byte(config.Secret))
+	if err != nil {
+		return nil, fmt.Errorf("sign access token: %w", err)
+	}
+
+	refreshToken, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.SetEX(ctx, refreshKey(refreshToken), strconv.Itoa(userID), config.RefreshTTL); err != nil {
+		return nil, fmt.Errorf("save refresh token: %w", err)
+	}
+	return &Pair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(config.AccessTTL / time.Second),
+	}, nil
+}
+
+// Validate 校验访问令牌的签名、有效期与签发者，并检查是否已注销
+func (s *Service) Validate(ctx context.Context, accessToken string) (*Claims, error) {
+	config := s.config.Load()
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(accessToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == config.KeyID {
+			return []byte(config.Secret), nil
+		}
+		for _, key := range config.PreviousKeys {
+			if kid == key.ID {
+				return []byte(key.Secret), nil
+			}
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithIssuer(config.Issuer), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.ID == "" {
+		return nil, fmt.Errorf("%w: missing jti", ErrInvalidToken)
+	}
+	revoked, err := s.store.Exists(ctx, denyKeyPrefix+claims.ID)
+	if err != nil {
+		return nil, fmt.Errorf("check token denylist: %w", err)
+	}
+	if revoked {
+		return nil, ErrRevokedToken
+	}
+	return claims, nil
+}
+
+// Refresh 用刷新令牌换取新的令牌，刷新令牌只能使用一次
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (*Pair, error) {
+	value, ok, err := s.store.Take(ctx, refreshKey(refreshToken))
+	if err != nil {
+		return nil, fmt.Errorf("load refresh token: %w", err)
+	}
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	userID, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	return s.Issue(ctx, userID)
+}
+
+// Revoke 注销访问令牌，refreshToken 不为空时同时作废刷新令牌；
+// 刷新令牌属于其他用户时返回 ErrInvalidToken，不注销任何令牌
+func (s *Service) Revoke(ctx context.Context, claims *Claims, refreshToken string) error {
+	if refreshToken != "" {
+		owner, _, ok, err := s.store.GetEX(ctx, refreshKey(refreshToken))
+		if err != nil {
+			return fmt.Errorf("load refresh token: %w", err)
+		}
+		if ok && owner != strconv.Itoa(claims.UserID) {
+			return ErrInvalidToken
+		}
+	}
+	if ttl := time.Until(claims.ExpiresAt.Time); ttl > 0 {
+		if err := s.store.SetEX(ctx, denyKeyPrefix+claims.ID, strconv.Itoa(claims.UserID), ttl); err != nil {
+			return fmt.Errorf("revoke access token: %w", err)
+		}
+	}
+	if refreshToken == "" {
+		return nil
+	}
+	if _, _, err := s.store.Take(ctx, refreshKey(refreshToken)); err != nil {
+		return fmt.Errorf("revoke refresh token: %w", err)
+	}
+	return nil
+}
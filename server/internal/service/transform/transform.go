@@ -1,88 +1,134 @@
 package services
 
 import (
-	"database/sql"
+	"context"
+	"encoding/base64"
 	"errors"
+	"feishu2md/server/internal/logger"
 	"feishu2md/server/internal/model"
+	"feishu2md/server/internal/repository/database"
 	"fmt"
+	"go.uber.org/zap"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// Repository 转换记录的存储，由 database.TransformRepository 实现
+type Repository interface {
+	Create(ctx context.Context, t *model.Transform) error
+	Get(ctx context.Context, id int) (*model.Transform, error)
+	FindByRevision(ctx context.Context, userID int, url string, revisionID int64) (*model.Transform, error)
+	Update(ctx context.Context, t *model.Transform) error
+	Delete(ctx context.Context, id int) error
+	ListByUser(ctx context.Context, userID int) ([]model.Transform, error)
+	List(ctx context.Context, filter database.TransformFilter) ([]model.TransformSummary, error)
+}
+
+// Indexer 全文索引，由 search.Index 实现
+type Indexer interface {
+	Index(ctx context.Context, t *model.Transform) error
+	Delete(ctx context.Context, id int) error
+}
+
 // TransformService 提供与转换相关的操作
 type TransformService struct {
-	DB *sql.DB
+	repo    Repository
+	indexer Indexer
 }
 
 // NewTransformService 创建 TransformService 实例
-func NewTransformService(db *sql.DB) *TransformService {
-	return &TransformService{DB: db}
+func NewTransformService(repo Repository) *TransformService {
+	return &TransformService{repo: repo}
+}
+
+// SetIndexer 设置全文索引，记录写入或删除后同步更新索引
+func (s *TransformService) SetIndexer(indexer Indexer) {
+	s.indexer = indexer
+}
+
+// index 更新记录的索引，索引失败不影响记录本身，可通过重建命令恢复
+func (s *TransformService) index(t *model.Transform) {
+	if s.indexer == nil {
+		return
+	}
+	if err := s.indexer.Index(context.Background(), t); err != nil {
+		logger.L.Error("Failed to index transform", zap.Int("id", t.ID), zap.Error(err))
+	}
 }
 
 // CreateTransform 创建一条新的 Transform 记录
 func (s *TransformService) CreateTransform(userID int, url string, result string, tittle string) (*model.Transform, error) {
-	// 创建新记录
-	transform := &model.Transform{
-		UserID:    userID,
-		Url:       url,
-		Result:    result, // 使用 Result 字段
-		Tittle:    tittle,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-
-	// 插入数据
-	query := `INSERT INTO transform (user_id, url, result, tittle,created_at, updated_at) VALUES (?, ?, ?, ?, ?)`
-	resultSet, err := s.DB.Exec(query, transform.UserID, transform.Url, transform.Result, transform.Tittle, transform.CreatedAt, transform.UpdatedAt)
-	if err != nil {
+	return s.CreateTransformRevision(userID, url, result, tittle, 0)
+}
+
+// CreateTransformRevision 创建一条带文档版本号的 Transform 记录
+func (s *TransformService) CreateTransformRevision(userID int, url string, result string, tittle string, revisionID int64) (*model.Transform, error) {
+	return s.create(&model.Transform{
+		UserID:     userID,
+		Url:        url,
+		Result:     result, // 使用 Result 字段
+		Tittle:     tittle,
+		RevisionID: revisionID,
+	})
+}
+
+// CreateTransformWithImages 创建一条 Transform 记录，同时保存每张图片的处理结果
+func (s *TransformService) CreateTransformWithImages(userID int, url string, result string, tittle string, images []model.ImageResult) (*model.Transform, error) {
+	return s.create(&model.Transform{
+		UserID: userID,
+		Url:    url,
+		Result: result,
+		Tittle: tittle,
+		Images: images,
+	})
+}
+
+// create 填写创建时间后插入记录
+func (s *TransformService) create(transform *model.Transform) (*model.Transform, error) {
+	now := time.Now()
+	transform.CreatedAt = now
+	transform.UpdatedAt = now
+
+	// 插入数据并回填 ID
+	if err := s.repo.Create(context.Background(), transform); err != nil {
 		return nil, fmt.Errorf("插入 Transform 记录失败: %v", err)
 	}
+	s.index(transform)
+	return transform, nil
+}
 
-	// 获取插入后的 ID
-	id, err := resultSet.LastInsertId() // 使用 LastInsertId 方法
+// FindTransformByRevision 查找用户已导出的某个文档版本，未找到时返回 nil
+func (s *TransformService) FindTransformByRevision(userID int, url string, revisionID int64) (*model.Transform, error) {
+	transform, err := s.repo.FindByRevision(context.Background(), userID, url, revisionID)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("获取插入后的 ID 失败: %v", err)
+		return nil, fmt.Errorf("查询 Transform 记录失败: %v", err)
 	}
-
-	// 设置 ID 并返回 Transform 记录
-	transform.ID = int(id)
 	return transform, nil
 }
 
 // GetTransform 获取一条 Transform 记录（通过 ID）
 func (s *TransformService) GetTransform(id int) (*model.Transform, error) {
-	var transform model.Transform
-
-	// 根据 ID 查找记录
-	query := `SELECT id, user_id, url, result, tittle,created_at, updated_at FROM transform WHERE id = ?`
-	row := s.DB.QueryRow(query, id)
-
-	// 填充数据
-	err := row.Scan(&transform.ID, &transform.UserID, &transform.Url, &transform.Result, &transform.Tittle, &transform.CreatedAt, &transform.UpdatedAt)
+	transform, err := s.repo.Get(context.Background(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, errors.New("Transform 记录未找到")
+	}
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("Transform 记录未找到")
-		}
 		return nil, fmt.Errorf("查询 Transform 记录失败: %v", err)
 	}
-
-	return &transform, nil
+	return transform, nil
 }
 
 // UpdateTransform 更新一条 Transform 记录
 func (s *TransformService) UpdateTransform(id int, url, result string, tittle string) (*model.Transform, error) {
-	var transform model.Transform
-
 	// 查找记录
-	query := `SELECT id, user_id, url, result, tittle,created_at, updated_at FROM transform WHERE id = ?`
-	row := s.DB.QueryRow(query, id)
-
-	// 填充数据
-	err := row.Scan(&transform.ID, &transform.UserID, &transform.Url, &transform.Result, &transform.Tittle, &transform.CreatedAt, &transform.UpdatedAt)
+	transform, err := s.GetTransform(id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("Transform 记录未找到")
-		}
-		return nil, fmt.Errorf("查询 Transform 记录失败: %v", err)
+		return nil, err
 	}
 
 	// 更新记录
@@ -90,66 +136,141 @@ func (s *TransformService) UpdateTransform(id int, url, result string, tittle st
 	transform.Result = result
 	transform.Tittle = tittle
 	transform.UpdatedAt = time.Now()
-
-	// 执行更新
-	updateQuery := `UPDATE transform SET url = ?, result = ?,tittle = ?, updated_at = ? WHERE id = ?`
-	_, err = s.DB.Exec(updateQuery, transform.Url, transform.Result, transform.Tittle, transform.UpdatedAt, transform.ID)
-	if err != nil {
+	if err := s.repo.Update(context.Background(), transform); err != nil {
 		return nil, fmt.Errorf("更新 Transform 记录失败: %v", err)
 	}
+	s.index(transform)
 
-	return &transform, nil
+	return transform, nil
 }
 
 // DeleteTransform 删除一条 Transform 记录
 func (s *TransformService) DeleteTransform(id int) error {
-	// 查找记录
-	query := `SELECT id FROM transform WHERE id = ?`
-	row := s.DB.QueryRow(query, id)
-
-	var transformID int
-	err := row.Scan(&transformID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return errors.New("Transform 记录未找到")
-		}
-		return fmt.Errorf("查询 Transform 记录失败: %v", err)
+	err := s.repo.Delete(context.Background(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		return errors.New("Transform 记录未找到")
 	}
-
-	// 删除记录
-	deleteQuery := `DELETE FROM transform WHERE id = ?`
-	_, err = s.DB.Exec(deleteQuery, transformID)
 	if err != nil {
 		return fmt.Errorf("删除 Transform 记录失败: %v", err)
 	}
-
+	if s.indexer != nil {
+		if err := s.indexer.Delete(context.Background(), id); err != nil {
+			logger.L.Error("Failed to remove transform from index", zap.Int("id", id), zap.Error(err))
+		}
+	}
 	return nil
 }
 
 // GetHistory 获取某个用户的所有历史记录
 func (s *TransformService) GetHistory(userID int) ([]model.Transform, error) {
-	var transforms []model.Transform
+	transforms, err := s.repo.ListByUser(context.Background(), userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询 Transform 记录失败: %v", err)
+	}
+	return transforms, nil
+}
+
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+// ListHistory 按游标分页返回用户的历史记录摘要
+func (s *TransformService) ListHistory(userID int, query model.HistoryQuery) (*model.HistoryPage, error) {
+	filter := database.TransformFilter{
+		UserID:  userID,
+		DocType: query.DocType,
+		Domain:  query.Domain,
+		Query:   strings.TrimSpace(query.Q),
+	}
+	var err error
+	if filter.BeforeID, err = decodeCursor(query.Cursor); err != nil {
+		return nil, invalidQuery("cursor", err)
+	}
+	if filter.From, err = parseDate(query.From, false); err != nil {
+		return nil, invalidQuery("from", err)
+	}
+	if filter.To, err = parseDate(query.To, true); err != nil {
+		return nil, invalidQuery("to", err)
+	}
 
-	// 根据 userID 查找历史记录
-	query := `SELECT id, user_id, url, result, tittle,created_at, updated_at FROM transform WHERE user_id = ? ORDER BY created_at DESC`
-	rows, err := s.DB.Query(query, userID)
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+	// 多取一条判断是否还有下一页
+	filter.Limit = limit + 1
+
+	items, err := s.repo.List(context.Background(), filter)
 	if err != nil {
 		return nil, fmt.Errorf("查询 Transform 记录失败: %v", err)
 	}
-	defer rows.Close()
+	page := &model.HistoryPage{Items: items}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		page.HasMore = true
+		page.NextCursor = encodeCursor(page.Items[limit-1].ID)
+	}
+	for i := range page.Items {
+		page.Items[i].Domain, page.Items[i].DocType = docInfo(page.Items[i].Url)
+	}
+	return page, nil
+}
 
-	// 遍历查询结果
-	for rows.Next() {
-		var transform model.Transform
-		if err := rows.Scan(&transform.ID, &transform.UserID, &transform.Url, &transform.Result, &transform.Tittle, &transform.CreatedAt, &transform.UpdatedAt); err != nil {
-			return nil, fmt.Errorf("扫描 Transform 记录失败: %v", err)
-		}
-		transforms = append(transforms, transform)
+// encodeCursor/decodeCursor 游标为上一页最后一条记录的 ID，编码后对调用方不透明
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("id:" + strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	value, ok := strings.CutPrefix(string(data), "id:")
+	if !ok {
+		return 0, errors.New("malformed cursor")
+	}
+	return strconv.Atoi(value)
+}
+
+// parseDate 解析日期，end 为 true 时只有日期的值表示当天结束
+func parseDate(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, errors.New("want 2006-01-02 or RFC3339")
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
 	}
+	return t, nil
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("读取 Transform 记录失败: %v", err)
+func invalidQuery(field string, err error) error {
+	return &model.ErrorResponse{
+		Code:    1000,
+		Message: fmt.Sprintf("参数错误: %s %v", field, err),
 	}
+}
 
-	return transforms, nil
+// docInfo 从文档链接中取出域名与文档类型
+func docInfo(rawURL string) (domain, docType string) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", ""
+	}
+	docType, _, _ = strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	return u.Hostname(), docType
 }
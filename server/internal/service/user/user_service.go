@@ -1,29 +1,37 @@
 package user
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 	"feishu2md/server/internal/model"
+	"feishu2md/server/internal/repository/database"
 	"fmt"
 	"golang.org/x/crypto/bcrypt"
+	"time"
 )
 
+// Repository 用户的存储，由 database.UserRepository 实现
+type Repository interface {
+	CountByPhone(ctx context.Context, phone string) (int, error)
+	Create(ctx context.Context, user *model.User) error
+	FindByPhone(ctx context.Context, phone string) (*model.User, error)
+}
+
 // UserService 提供与用户相关的操作
 type UserService struct {
-	DB *sql.DB
+	repo Repository
 }
 
 // NewUserService 创建 UserService 实例
-func NewUserService(db *sql.DB) *UserService {
-	return &UserService{DB: db}
+func NewUserService(repo Repository) *UserService {
+	return &UserService{repo: repo}
 }
 
 // RegisterUser 处理用户注册
 func (s *UserService) RegisterUser(phone, password string) (*model.User, error) {
+	ctx := context.Background()
 	// 1. 检查手机号是否已存在
-	var count int
-	query := `SELECT COUNT(*) FROM user WHERE phone = ?`
-	err := s.DB.QueryRow(query, phone).Scan(&count)
+	count, err := s.repo.CountByPhone(ctx, phone)
 	if err != nil {
 		return nil, fmt.Errorf("检查手机号是否已存在失败: %v", err)
 	}
@@ -38,40 +46,26 @@ func (s *UserService) RegisterUser(phone, password string) (*model.User, error)
 		return nil, fmt.Errorf("密码加密失败: %v", err)
 	}
 
-	// 3. 插入用户数据
-	query = `INSERT INTO user (phone, password) VALUES (?, ?)`
-	result, err := s.DB.Exec(query, phone, string(hashedPassword))
-	if err != nil {
-		return nil, fmt.Errorf("插入用户数据失败: %v", err)
-	}
-
-	// 4. 获取插入后的用户ID
-	id, err := result.LastInsertId()
-	if err != nil {
-		return nil, fmt.Errorf("获取插入后ID失败: %v", err)
-	}
-
-	// 5. 返回用户信息
+	// 3. 插入用户数据并回填 ID
+	now := time.Now()
 	user := &model.User{
-		ID:       int(id),
-		Phone:    phone,
-		Password: string(hashedPassword),
+		Phone:     phone,
+		Password:  string(hashedPassword),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.repo.Create(ctx, user); err != nil {
+		return nil, fmt.Errorf("插入用户数据失败: %v", err)
 	}
 	return user, nil
 }
 
 // LoginUser 处理用户登录
 func (s *UserService) LoginUser(phone, password string) (*model.User, error) {
-	var user model.User
-
 	// 根据手机号查找用户
-	query := `SELECT id, phone, password FROM user WHERE phone = ?`
-	row := s.DB.QueryRow(query, phone)
-
-	// 填充用户数据
-	err := row.Scan(&user.ID, &user.Phone, &user.Password)
+	user, err := s.repo.FindByPhone(context.Background(), phone)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, database.ErrNotFound) {
 			return nil, errors.New("用户不存在")
 		}
 		return nil, fmt.Errorf("查询用户失败: %v", err)
@@ -83,5 +77,5 @@ func (s *UserService) LoginUser(phone, password string) (*model.User, error) {
 	}
 
 	// 密码验证成功，返回用户
-	return &user, nil
+	return user, nil
 }
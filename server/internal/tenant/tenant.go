@@ -0,0 +1,134 @@
+// Package tenant 按文档域名区分飞书租户（飞书、Lark 与私有化部署），每个租户使用各自的应用凭证、
+// 开放平台地址与网页授权地址。
+package tenant
+
+import (
+	"errors"
+	"feishu2md/server/pkg/conf"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultDomain 默认租户的域名，使用 feishu.app_id/app_secret
+const DefaultDomain = "feishu.cn"
+
+// ErrUnknownDomain 域名既不是已配置的租户，也不是飞书的公开域名，不能将应用凭证发往该域名
+var ErrUnknownDomain = errors.New("unknown feishu domain")
+
+// publicDomains 未单独配置租户时可以使用 feishu 顶层应用凭证的域名
+var publicDomains = map[string]bool{
+	"feishu.cn":     true,
+	"larksuite.com": true,
+	"f.mioffice.cn": true,
+}
+
+// Tenant 一个飞书租户
+type Tenant struct {
+	Name         string `json:"name"`
+	Domain       string `json:"domain"`
+	AppID        string `json:"app_id"`
+	AppSecret    string `json:"-"`
+	OpenBaseURL  string `json:"-"`
+	AuthorizeURL string `json:"authorize_url"`
+}
+
+// Registry 域名到租户的映射
+type Registry struct {
+	fallback conf.FeishuConfig
+	tenants  map[string]Tenant
+	ordered  []Tenant
+}
+
+// NewRegistry 按配置创建 Registry，feishu 顶层的应用凭证作为 feishu.cn 租户
+func NewRegistry(cfg conf.FeishuConfig) *Registry {
+	r := &Registry{fallback: cfg, tenants: make(map[string]Tenant)}
+	r.add(r.fromDefault(DefaultDomain))
+	for _, t := range cfg.Tenants {
+		r.add(Tenant{
+			Name:         t.Name,
+			Domain:       t.Domain,
+			AppID:        t.AppID,
+			AppSecret:    t.AppSecret,
+			OpenBaseURL:  t.OpenBaseURL,
+			AuthorizeURL: t.AuthorizeURL,
+		})
+	}
+	return r
+}
+
+func (r *Registry) add(t Tenant) {
+	t = withDefaults(t)
+	r.tenants[t.Domain] = t
+	r.ordered = append(r.ordered, t)
+}
+
+// fromDefault 使用 feishu 顶层应用凭证的租户，配置了 open_base_url 时所有域名都使用该地址
+func (r *Registry) fromDefault(domain string) Tenant {
+	name := domain
+	if domain == DefaultDomain {
+		name = "feishu"
+	}
+	return Tenant{
+		Name:        name,
+		Domain:      domain,
+		AppID:       r.fallback.AppID,
+		AppSecret:   r.fallback.AppSecret,
+		OpenBaseURL: r.fallback.OpenBaseURL,
+	}
+}
+
+func withDefaults(t Tenant) Tenant {
+	if t.Name == "" {
+		t.Name = t.Domain
+	}
+	if t.OpenBaseURL == "" {
+		t.OpenBaseURL = "https://open." + t.Domain
+	}
+	t.OpenBaseURL = strings.TrimRight(t.OpenBaseURL, "/")
+	if t.AuthorizeURL == "" {
+		t.AuthorizeURL = "https://accounts." + t.Domain + "/open-apis/authen/v1/authorize"
+	}
+	return t
+}
+
+// Resolve 返回域名对应的租户，空域名为默认租户；未配置的飞书公开域名使用 feishu 顶层的应用凭证，
+// 其余域名返回 ErrUnknownDomain
+func (r *Registry) Resolve(domain string) (Tenant, error) {
+	if domain == "" {
+		domain = DefaultDomain
+	}
+	if t, ok := r.tenants[domain]; ok {
+		return t, nil
+	}
+	if !publicDomains[domain] {
+		return Tenant{}, ErrUnknownDomain
+	}
+	return withDefaults(r.fromDefault(domain)), nil
+}
+
+// DomainPattern 匹配全部可用域名（已配置的租户与飞书公开域名）的正则分组，供解析文档链接使用
+func (r *Registry) DomainPattern() string {
+	seen := make(map[string]bool)
+	var domains []string
+	for domain := range publicDomains {
+		seen[domain] = true
+		domains = append(domains, domain)
+	}
+	for _, t := range r.ordered {
+		if !seen[t.Domain] {
+			seen[t.Domain] = true
+			domains = append(domains, t.Domain)
+		}
+	}
+	sort.Strings(domains)
+	for i, domain := range domains {
+		domains[i] = regexp.QuoteMeta(domain)
+	}
+	return "(" + strings.Join(domains, "|") + ")"
+}
+
+// Tenants 按配置顺序返回全部已配置的租户，第一个为默认租户
+func (r *Registry) Tenants() []Tenant {
+	return append([]Tenant(nil), r.ordered...)
+}
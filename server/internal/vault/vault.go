@@ -0,0 +1,89 @@
+// Package vault 用 AES-256-GCM 加密保存在数据库中的敏感字段，如飞书用户令牌。
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix 密文的前缀，没有该前缀的值视为加密前写入的明文
+const prefix = "v1:"
+
+// ErrDecrypt 密文被篡改，或加密使用的密钥已不在配置中
+var ErrDecrypt = errors.New("vault: unable to decrypt value")
+
+// Vault 用当前密钥加密，解密时依次尝试当前密钥与旧密钥
+type Vault struct {
+	current  cipher.AEAD
+	previous []cipher.AEAD
+}
+
+// New 创建 Vault，secret 为当前密钥，previous 为轮换前的密钥，只用于解密
+func New(secret string, previous ...string) (*Vault, error) {
+	current, err := newAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+	v := &Vault{current: current}
+	for _, old := range previous {
+		aead, err := newAEAD(old)
+		if err != nil {
+			return nil, err
+		}
+		v.previous = append(v.previous, aead)
+	}
+	return v, nil
+}
+
+// newAEAD 由任意长度的密钥派生 AES-256 密钥
+func newAEAD(secret string) (cipher.AEAD, error) {
+	if secret == "" {
+		return nil, errors.New("vault: empty secret")
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal 加密 plain，空字符串原样返回
+func (v *Vault) Seal(plain string) (string, error) {
+	if plain == "" {
+		return "", nil
+	}
+	nonce := make([]byte, v.current.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+	sealed := v.current.Seal(nonce, nonce, []byte(plain), nil)
+	return prefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open 解密 Seal 的结果；没有前缀的值是加密前保存的明文，原样返回，下次写入时加密
+func (v *Vault) Open(sealed string) (string, error) {
+	if !strings.HasPrefix(sealed, prefix) {
+		return sealed, nil
+	}
+	data, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(sealed, prefix))
+	if err != nil {
+		return "", ErrDecrypt
+	}
+	for _, aead := range append([]cipher.AEAD{v.current}, v.previous...) {
+		size := aead.NonceSize()
+		if len(data) < size {
+			return "", ErrDecrypt
+		}
+		if plain, err := aead.Open(nil, data[:size], data[size:], nil); err == nil {
+			return string(plain), nil
+		}
+	}
+	return "", ErrDecrypt
+}
@@ -1,6 +1,10 @@
 package conf
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 type LogConfig struct {
 	Path          string `yaml:"path"` // 严格匹配YAML键
@@ -16,6 +20,68 @@ type LogConfig struct {
 type FeishuConfig struct {
 	AppID     string `yaml:"app_id"`     //  严格匹配
 	AppSecret string `yaml:"app_secret"` //  严格匹配
+	// OpenBaseURL 开放平台地址，为空时按文档域名使用 https://open.<domain>
+	OpenBaseURL string `yaml:"open_base_url"`
+	// Tenants 其他域名的租户（如 Lark、私有化部署），未列出的飞书公开域名使用上面的应用凭证
+	Tenants []FeishuTenant `yaml:"tenants"`
+	// TokenRefreshBefore tenant_access_token 剩余有效期不足该时长时重新获取
+	TokenRefreshBefore time.Duration `yaml:"token_refresh_before"`
+	// HTTP 所有飞书客户端共享的连接池与超时
+	HTTP FeishuHTTPConfig `yaml:"http"`
+	// Retry 开放平台请求遇到限流或服务端错误时的重试
+	Retry FeishuRetryConfig `yaml:"retry"`
+	// RateLimit 按租户与 API 类别限制访问开放平台的速率
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+}
+
+// RateLimitConfig 开放平台的限流额度，API 类别为 blocks（文档块）、media（图片与文件）、sheets、bitable，
+// 未配置额度的类别不限流
+type RateLimitConfig struct {
+	// Backend redis 在多个实例间共享额度，memory 只在本实例内限流
+	Backend string `yaml:"backend"`
+	// Budgets 各 API 类别的默认额度
+	Budgets map[string]RateBudget `yaml:"budgets"`
+	// Tenants 按租户名（小写）覆盖部分类别的额度
+	Tenants map[string]map[string]RateBudget `yaml:"tenants"`
+}
+
+// RateBudget 每秒 Rate 个请求，最多连续 Burst 个
+type RateBudget struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// FeishuRetryConfig 开放平台请求的重试策略，等待时间按指数增长并加入随机抖动
+type FeishuRetryConfig struct {
+	// MaxAttempts 包括首次请求在内的最大次数，1 表示不重试
+	MaxAttempts     int           `yaml:"max_attempts"`
+	InitialInterval time.Duration `yaml:"initial_interval"`
+	MaxInterval     time.Duration `yaml:"max_interval"`
+	// MaxElapsedTime 首次请求起的最长重试时间，超出后返回最后一次的错误
+	MaxElapsedTime time.Duration `yaml:"max_elapsed_time"`
+}
+
+// FeishuHTTPConfig 访问开放平台的 HTTP 连接配置
+type FeishuHTTPConfig struct {
+	// Timeout 单个请求的超时，包括读取响应体
+	Timeout             time.Duration `yaml:"timeout"`
+	DialTimeout         time.Duration `yaml:"dial_timeout"`
+	IdleConnTimeout     time.Duration `yaml:"idle_conn_timeout"`
+	MaxIdleConnsPerHost int           `yaml:"max_idle_conns_per_host"`
+}
+
+// FeishuTenant 一个文档域名对应的飞书应用
+type FeishuTenant struct {
+	// Name 日志与监控指标中的租户名，默认为 Domain
+	Name string `yaml:"name"`
+	// Domain 文档链接的域名，如 larksuite.com、f.mioffice.cn
+	Domain    string `yaml:"domain"`
+	AppID     string `yaml:"app_id"`
+	AppSecret string `yaml:"app_secret"`
+	// OpenBaseURL 开放平台地址，默认为 https://open.<domain>
+	OpenBaseURL string `yaml:"open_base_url"`
+	// AuthorizeURL 网页授权页地址，默认为 https://accounts.<domain>/open-apis/authen/v1/authorize
+	AuthorizeURL string `yaml:"authorize_url"`
 }
 
 type StorageConfig struct {
@@ -23,12 +89,38 @@ type StorageConfig struct {
 	LocalDir string `yaml:"local_dir"`
 	Endpoint string `yaml:"endpoint"`
 	Bucket   string `yaml:"bucket"`
+	// URLSecret 本地存储访问链接的签名密钥，没有有效签名的请求无法读取文件
+	URLSecret string `yaml:"url_secret"`
 }
 
 type ImgConfig struct {
-	DownloadRate int           `yaml:"download_rate"`
-	MaxRetries   int           `yaml:"max_retries"`
-	MaxWaitTime  time.Duration `yaml:"max_wait_time"`
+	DownloadRate int `yaml:"download_rate"`
+	// MaxWaitTime 每张图片等待限流额度的最长时间，超过后跳过该图片
+	MaxWaitTime time.Duration `yaml:"max_wait_time"`
+	// FailurePlaceholder 替换 Markdown 中处理失败图片的地址，为空时保留图片 token
+	FailurePlaceholder string `yaml:"failure_placeholder"`
+	// Strict 任一图片处理失败时整个转换失败，请求中的 strict_images 可覆盖
+	Strict bool `yaml:"strict"`
+	// Process 上传前对图片的处理，请求中的 image_process 整体覆盖
+	Process ImageProcessConfig `yaml:"process"`
+	// CWebPPath cwebp 可执行文件的路径，转换为 WebP 时需要
+	CWebPPath string `yaml:"cwebp_path"`
+	// MaxPixels 处理的图片像素数（宽×高）上限，超过时不解码、原样上传，0 表示默认的 2500 万
+	MaxPixels int64 `yaml:"max_pixels"`
+}
+
+// ImageProcessConfig 图片上传前的处理，零值表示原样上传；同时用于请求体，因此带有 json 标签
+type ImageProcessConfig struct {
+	// MaxWidth 宽度超过时等比缩小到该宽度，0 表示不缩放
+	MaxWidth int `yaml:"max_width" json:"max_width"`
+	// Format 转换后的格式：jpeg、png 或 webp，为空时保持原格式
+	Format string `yaml:"format" json:"format"`
+	// Quality jpeg 与 webp 的压缩质量，1-100，0 表示默认值 85
+	Quality int `yaml:"quality" json:"quality"`
+	// StripMetadata 重新编码以去除 EXIF 等元数据
+	StripMetadata bool `yaml:"strip_metadata" json:"strip_metadata"`
+	// ThumbnailWidth 另外生成该宽度的缩略图，0 表示不生成
+	ThumbnailWidth int `yaml:"thumbnail_width" json:"thumbnail_width"`
 }
 type CaptchaConfig struct {
 	CaptchaType   string        `yaml:"captcha_type"`
@@ -36,3 +128,140 @@ type CaptchaConfig struct {
 	ExpireTime    time.Duration `yaml:"expire_time"`
 	MaxStore      int           `yaml:"max_store"`
 }
+
+// JWTConfig 登录令牌配置，Secret 为当前签名密钥，PreviousKeys 为轮换后仍接受验证的旧密钥
+type JWTConfig struct {
+	Issuer       string        `yaml:"issuer"`
+	KeyID        string        `yaml:"key_id"`
+	Secret       string        `yaml:"secret"`
+	PreviousKeys []JWTKey      `yaml:"previous_keys"`
+	AccessTTL    time.Duration `yaml:"access_ttl"`
+	RefreshTTL   time.Duration `yaml:"refresh_ttl"`
+}
+
+// JWTKey 带 ID 的签名密钥，ID 写入令牌头部的 kid
+type JWTKey struct {
+	ID     string `yaml:"id"`
+	Secret string `yaml:"secret"`
+}
+
+// TokenVaultConfig 服务端保存的飞书用户令牌，令牌加密后写入数据库，并在过期前由后台任务刷新
+type TokenVaultConfig struct {
+	// Secret 加密密钥，轮换时把旧密钥移到 PreviousSecrets，已保存的令牌下次刷新时改用新密钥加密
+	Secret          string   `yaml:"secret"`
+	PreviousSecrets []string `yaml:"previous_secrets"`
+	// RefreshInterval 后台检查即将过期令牌的间隔，0 表示只在使用时刷新
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+	// RefreshBefore 后台任务刷新在该时长内过期的令牌
+	RefreshBefore time.Duration `yaml:"refresh_before"`
+}
+
+// APIKeyConfig API Key 配置，限流与配额为创建 Key 时未指定的默认值，0 表示不限制
+type APIKeyConfig struct {
+	RateLimit  int `yaml:"rate_limit"`  // 每分钟请求数
+	DailyQuota int `yaml:"daily_quota"` // 每天请求数
+	MaxPerUser int `yaml:"max_per_user"`
+}
+
+// SearchConfig 全文索引配置
+type SearchConfig struct {
+	// Path 索引文件路径，索引始终使用 SQLite，与业务数据库的类型无关
+	Path string `yaml:"path"`
+}
+
+type DatabaseConfig struct {
+	Type     string `yaml:"type"` // mysql / postgres / sqlite
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	DBName   string `yaml:"dbname"`
+	SSLMode  string `yaml:"sslmode"` // postgres 使用
+	// Path sqlite 数据库文件路径，:memory: 表示内存数据库
+	Path string `yaml:"path"`
+	// Params 追加到连接串的参数，如 charset=utf8mb4&parseTime=True&loc=Local
+	Params          string        `yaml:"params"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+}
+
+// pgQuote 转义 postgres 连接串中单引号内的值
+var pgQuote = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// DSN 按数据库类型生成连接串
+func (c *DatabaseConfig) DSN() string {
+	var dsn string
+	switch c.Type {
+	case "postgres":
+		dsn = fmt.Sprintf("host=%s port=%d user=%s password='%s' dbname=%s", c.Host, c.Port, c.Username, pgQuote.Replace(c.Password), c.DBName)
+		if c.SSLMode != "" {
+			dsn += " sslmode=" + c.SSLMode
+		}
+		if c.Params != "" {
+			dsn += " " + c.Params
+		}
+		return dsn
+	case "sqlite":
+		dsn = "file:" + c.Path
+	default:
+		dsn = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", c.Username, c.Password, c.Host, c.Port, c.DBName)
+	}
+	if c.Params != "" {
+		dsn += "?" + c.Params
+	}
+	return dsn
+}
+
+// RedisConfig Redis 连接配置，mode 为 standalone 时连接 addr，sentinel 与 cluster 时连接 addrs
+type RedisConfig struct {
+	// Mode standalone、sentinel 或 cluster，为空时为 standalone
+	Mode string `yaml:"mode"`
+	Addr string `yaml:"addr"` // host:port
+	// Addrs sentinel 模式为哨兵地址，cluster 模式为集群节点地址
+	Addrs []string `yaml:"addrs"`
+	// MasterName sentinel 模式下主节点的名称
+	MasterName       string `yaml:"master_name"`
+	Username         string `yaml:"username"`
+	Password         string `yaml:"password"`
+	SentinelUsername string `yaml:"sentinel_username"`
+	SentinelPassword string `yaml:"sentinel_password"`
+	DB               int    `yaml:"db"` // cluster 模式只能为 0
+	// KeyPrefix 所有 key 的前缀，多个应用或环境共用一个 Redis 时区分命名空间
+	KeyPrefix    string        `yaml:"key_prefix"`
+	MaxRetries   int           `yaml:"max_retries"`
+	DialTimeout  time.Duration `yaml:"dial_timeout"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	// PoolSize 每个节点的最大连接数，0 表示使用 go-redis 的默认值
+	PoolSize        int            `yaml:"pool_size"`
+	MinIdleConns    int            `yaml:"min_idle_conns"`
+	PoolTimeout     time.Duration  `yaml:"pool_timeout"`
+	ConnMaxIdleTime time.Duration  `yaml:"conn_max_idle_time"`
+	TLS             RedisTLSConfig `yaml:"tls"`
+	// HealthInterval Redis 不可用期间检查其是否恢复的间隔，期间缓存与限流使用进程内实现
+	HealthInterval time.Duration `yaml:"health_interval"`
+}
+
+// RedisTLSConfig 连接 Redis 的 TLS 配置，证书文件均为 PEM 格式
+type RedisTLSConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// CAFile 校验服务端证书的 CA，为空时使用系统证书
+	CAFile string `yaml:"ca_file"`
+	// CertFile 与 KeyFile 客户端证书，服务端要求双向认证时配置
+	CertFile   string `yaml:"cert_file"`
+	KeyFile    string `yaml:"key_file"`
+	ServerName string `yaml:"server_name"`
+	// InsecureSkipVerify 不校验服务端证书，仅用于测试环境
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+}
+
+type CORSConfig struct {
+	// AllowOrigins 允许跨域访问的来源，"*" 表示允许全部
+	AllowOrigins []string `yaml:"allow_origins"`
+}
+
+type AdminConfig struct {
+	// Token 管理接口的访问令牌，通过请求头 X-Admin-Token 传递，为空时禁用管理接口
+	Token string `yaml:"token"`
+}
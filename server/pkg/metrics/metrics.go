@@ -45,6 +45,50 @@ var (
 		},
 		[]string{"method", "path"},
 	)
+
+	// FeishuAPIRequests 按租户统计的开放平台请求，status 为 HTTP 状态码，网络错误时为 error
+	FeishuAPIRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "feishu_api_requests_total",
+			Help: "Feishu Open API requests by tenant",
+		},
+		[]string{"tenant", "status"},
+	)
+
+	FeishuAPIDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "feishu_api_request_duration_seconds",
+			Help:    "Feishu Open API request duration by tenant",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5},
+		},
+		[]string{"tenant"},
+	)
+
+	// FeishuAPIRetries 按租户与原因统计的开放平台请求重试，reason 为 rate_limited 或 server_error
+	FeishuAPIRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "feishu_api_retries_total",
+			Help: "Retried Feishu Open API requests by tenant",
+		},
+		[]string{"tenant", "reason"},
+	)
+
+	// TenantTransforms 按租户与文档类型统计的转换，result 为 success 或 error
+	TenantTransforms = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "feishu_transforms_total",
+			Help: "Document transforms by tenant",
+		},
+		[]string{"tenant", "doc_type", "result"},
+	)
+
+	// RedisUp Redis 是否可用，为 0 时缓存与限流已降级为进程内实现
+	RedisUp = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "redis_up",
+			Help: "Whether Redis is reachable",
+		},
+	)
 )
 
 func init() {
@@ -54,5 +98,10 @@ func init() {
 		ErrorRequests,
 		RequestDuration,
 		QPS,
+		FeishuAPIRequests,
+		FeishuAPIDuration,
+		FeishuAPIRetries,
+		TenantTransforms,
+		RedisUp,
 	)
 }
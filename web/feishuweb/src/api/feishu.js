@@ -9,4 +9,58 @@ export const parseFeishuDoc = (data, token) => {
         },
         data: data
     })
-}
\ No newline at end of file
+}
+
+// 以 Server-Sent Events 接收解析进度，每个进度事件调用 onProgress，最后返回与 parseFeishuDoc 相同格式的结果；
+// 服务端不支持流式接口或令牌需要刷新时改用普通接口
+export const streamFeishuDoc = async (data, token, onProgress) => {
+    const response = await fetch(`${request.defaults.baseURL}/v1/transform/stream`, {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json',
+            Authorization: `Bearer ${token}`
+        },
+        body: JSON.stringify(data)
+    })
+    const contentType = response.headers.get('Content-Type') || ''
+    if (!response.ok || !response.body || !contentType.startsWith('text/event-stream')) {
+        return parseFeishuDoc(data, token)
+    }
+
+    const reader = response.body.getReader()
+    const decoder = new TextDecoder()
+    let buffer = ''
+    for (;;) {
+        const { value, done } = await reader.read()
+        if (done) break
+        buffer += decoder.decode(value, { stream: true })
+        let end
+        while ((end = buffer.indexOf('\n\n')) >= 0) {
+            const event = parseEvent(buffer.slice(0, end))
+            buffer = buffer.slice(end + 2)
+            if (!event) continue
+            if (event.name === 'progress') {
+                onProgress && onProgress(event.data)
+            } else if (event.name === 'result') {
+                return { code: 0, data: event.data }
+            } else if (event.name === 'error') {
+                return event.data
+            }
+        }
+    }
+    return { code: 1007, message: '连接已断开' }
+}
+
+// parseEvent 解析一个事件块，忽略以冒号开头的保活注释
+function parseEvent(block) {
+    let name = 'message'
+    const data = []
+    for (const line of block.split('\n')) {
+        if (line.startsWith('event:')) {
+            name = line.slice(6).trim()
+        } else if (line.startsWith('data:')) {
+            data.push(line.slice(5))
+        }
+    }
+    return data.length ? { name, data: JSON.parse(data.join('\n')) } : null
+}
@@ -1,5 +1,5 @@
 // store/feishu.js
-import { parseFeishuDoc } from '@/api/feishu'
+import { streamFeishuDoc } from '@/api/feishu'
 
 const state = () => ({
     isLoading: false,
@@ -20,7 +20,8 @@ const mutations = {
 }
 
 const actions = {
-    async parseDocument({ commit }, { url, id, user_access_token, withImageDownload, isFile }) {
+    // onProgress 接收服务端推送的解析进度
+    async parseDocument({ commit }, { url, withImageDownload, isFile, onProgress }) {
         commit('SET_LOADING', true)
         commit('SET_ERROR', null)
         try {
@@ -31,16 +32,17 @@ const actions = {
             }
 
             const data = {
-                id: id,
                 url: url,
                 collection: 'default', // 默认集合，可根据需求修改
-                access_key: token,
-                user_access_token: user_access_token,
                 with_image_download: withImageDownload,
                 is_file: isFile
             }
 
-            const response = await parseFeishuDoc(data, token)
+            const response = await streamFeishuDoc(data, token, onProgress)
+            if (response.code !== 0) {
+                commit('SET_ERROR', response.message || '解析失败')
+                return
+            }
             commit('SET_DOC_DATA', response.data)
         } catch (error) {
             commit('SET_ERROR', error.message || '解析失败')
@@ -0,0 +1,51 @@
+// 飞书网页授权：跳转到租户的授权页，回调时校验 state 并取出授权码与租户域名
+// defaultTenant 未获取到服务端租户列表时使用
+const defaultTenant = {
+    domain: 'feishu.cn',
+    app_id: 'cli_a72e872fe4fbd00e',
+    authorize_url: 'https://accounts.feishu.cn/open-apis/authen/v1/authorize'
+}
+const redirectUri = 'http://localhost:3000'
+const scope = [
+    'offline_access', // 服务端需要刷新令牌才能长期代表用户访问文档
+    'docx:document',
+    'docx:document:readonly',
+    'drive:drive',
+    'drive:drive:readonly',
+    'sheets:spreadsheet',
+    'sheets:spreadsheet:read',
+    'sheets:spreadsheet:readonly',
+    'wiki:node:read',
+    'wiki:wiki',
+    'wiki:wiki:readonly'
+].join(' ')
+
+const stateKey = 'feishuAuthState'
+
+// purpose 为 login（飞书登录）或 link（已登录用户关联飞书账号），tenant 为 /api/feishu/tenants 返回的租户
+export function redirectToFeishu(purpose, tenant = defaultTenant) {
+    const bytes = new Uint8Array(16)
+    window.crypto.getRandomValues(bytes)
+    const state = Array.from(bytes, b => b.toString(16).padStart(2, '0')).join('')
+    sessionStorage.setItem(stateKey, JSON.stringify({ state, purpose, domain: tenant.domain }))
+    const params = new URLSearchParams({
+        client_id: tenant.app_id,
+        redirect_uri: redirectUri,
+        state,
+        scope
+    })
+    window.location.href = `${tenant.authorize_url}?${params}`
+}
+
+// 返回 { code, purpose, domain, redirectUri }，state 不匹配时返回 null；授权码只能使用一次，读取后从地址栏移除
+export function takeFeishuCallback() {
+    const params = new URLSearchParams(window.location.search)
+    const code = params.get('code')
+    const saved = JSON.parse(sessionStorage.getItem(stateKey) || 'null')
+    sessionStorage.removeItem(stateKey)
+    window.history.replaceState(null, '', window.location.pathname + window.location.hash)
+    if (!code || !saved || saved.state !== params.get('state')) {
+        return null
+    }
+    return { code, purpose: saved.purpose, domain: saved.domain || defaultTenant.domain, redirectUri }
+}
@@ -44,7 +44,21 @@ request.interceptors.response.use(
         // 返回处理后的响应数据
         return res;
     },
-    error => {
+    async error => {
+        const config = error.config || {};
+        // 访问令牌过期时用刷新令牌换取新令牌，并重试一次原请求
+        if (error.response && error.response.status === 401 && !config._retried && !isAuthRequest(config.url)) {
+            config._retried = true;
+            try {
+                const token = await refreshToken();
+                config.headers.Authorization = `Bearer ${token}`;
+                return request(config);
+            } catch (e) {
+                localStorage.removeItem('token');
+                localStorage.removeItem('refreshToken');
+                router.push('/login');
+            }
+        }
         // 如果响应发生错误，打印错误信息以便调试
         console.error('response error: ' + error);
         // 返回Promise.reject以通知响应发生错误
@@ -52,5 +66,33 @@ request.interceptors.response.use(
     }
 )
 
+// 登录、刷新与注销请求本身返回 401 时不再刷新令牌
+function isAuthRequest(url) {
+    return ['/api/login', '/api/token/refresh', '/api/logout'].some(path => (url || '').endsWith(path));
+}
+
+// 同一时间只发起一次刷新，并发的请求共用刷新结果
+let refreshing = null;
+
+function refreshToken() {
+    if (!refreshing) {
+        const refresh_token = localStorage.getItem('refreshToken');
+        refreshing = (refresh_token ? request.post('/api/token/refresh', { refresh_token }) : Promise.reject(new Error('no refresh token')))
+            .then(res => {
+                if (res.code !== 0) {
+                    throw new Error(res.message);
+                }
+                localStorage.setItem('token', res.data.token);
+                localStorage.setItem('refreshToken', res.data.refresh_token);
+                localStorage.setItem('tokenTimestamp', Date.now().toString());
+                return res.data.token;
+            })
+            .finally(() => {
+                refreshing = null;
+            });
+    }
+    return refreshing;
+}
+
 // 导出配置好的axios实例，以便在其他模块中使用
 export default request;
\ No newline at end of file